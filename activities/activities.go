@@ -0,0 +1,370 @@
+// Package activities holds the activities of the withdrawal workflows. They talk to the withdrawal server through
+// the api client and poll the automated approvers.
+package activities
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	apiclient "github.com/bartke/cadence-withdrawal-approval/api/client"
+	"github.com/bartke/cadence-withdrawal-approval/common"
+	"github.com/bartke/cadence-withdrawal-approval/httpclient"
+	"github.com/bartke/cadence-withdrawal-approval/notify"
+	"github.com/bartke/cadence-withdrawal-approval/tracing"
+	"go.uber.org/cadence"
+	"go.uber.org/cadence/activity"
+	"go.uber.org/cadence/client"
+	"go.uber.org/zap"
+)
+
+// This is registration process where you register all your activity handlers. The activities keep the names they
+// had as functions of the main package, so scheduled activities and recorded histories keep resolving them.
+func init() {
+	a := Default
+	register(a.CreateWithdrawal, "main.createWithdrawalActivity")
+	register(a.WaitForManual, "main.waitForManualActivity")
+	register(a.WaitForAutomated, "main.waitForAutomatedActivity")
+	register(a.AutoAction, "main.autoAction")
+	register(a.Payment, "main.paymentActivity")
+	register(a.GetStatus, "main.getStatus")
+	register(a.NotifyCustomer, "main.notifyCustomerActivity")
+	register(a.ListWithdrawals, "main.listWithdrawalsActivity")
+	register(a.ListOpenWithdrawalWorkflows, "main.listOpenWithdrawalWorkflowsActivity")
+	register(a.ListPayouts, "main.listPayoutsActivity")
+}
+
+func register(fn interface{}, name string) {
+	activity.RegisterWithOptions(fn, activity.RegisterOptions{Name: name})
+}
+
+// Activities holds what the withdrawal activities depend on. The cadence client registers activities once per
+// process, so the registered methods run with the Activities a worker puts into its BackgroundActivityContext with
+// WithContext, and with Default for workers without. Workers with different configurations can run side by side
+// and tests inject fakes the same way.
+type Activities struct {
+	// Server is the client of the withdrawal server, which stores the withdrawals.
+	Server *apiclient.Client
+	// Approvers locates and paces the automated approvers.
+	Approvers common.ApproversConfig
+	// Notify selects the customer notification channels, none if empty.
+	Notify notify.Config
+	// Workflow holds the activity options new withdrawal workflows start with.
+	Workflow common.WorkflowConfig
+	// HTTP sends the requests to the approvers, and to the server when it is also the client of Server.
+	HTTP *httpclient.Client
+	// Cadence lists the open workflows for reconciliation.
+	Cadence client.Client
+	// Logger replaces the worker logger when set.
+	Logger *zap.Logger
+}
+
+// Default is registered, so workflow code references the activities through it. It runs when the worker brings no
+// Activities.
+var Default = New(common.Configuration{
+	Server: common.ServerConfig{URL: "http://localhost:8099"},
+	Approvers: common.ApproversConfig{
+		Sports: "http://localhost:8091",
+		Casino: "http://localhost:8092",
+		Poll:   common.DefaultApproverPoll,
+	},
+	Workflow: common.DefaultWorkflowConfig(),
+	HTTP:     httpclient.DefaultConfig(),
+}, nil, nil)
+
+// New returns the activities for the configuration. A nil logger keeps the worker logger.
+func New(c common.Configuration, cadenceClient client.Client, logger *zap.Logger) *Activities {
+	httpClient := NewHTTPClient(c.HTTP)
+	return &Activities{
+		Server:    apiclient.New(c.Server.URL, httpClient),
+		Approvers: c.Approvers,
+		Notify:    c.Notifications,
+		Workflow:  c.Workflow,
+		HTTP:      httpClient,
+		Cadence:   cadenceClient,
+		Logger:    logger,
+	}
+}
+
+// NewHTTPClient traces the requests of the activities as children of the activity span.
+func NewHTTPClient(c httpclient.Config) *httpclient.Client {
+	return httpclient.New(c, tracing.Transport(http.DefaultTransport))
+}
+
+type contextKey struct{}
+
+// WithContext returns a context carrying a, pass it as the BackgroundActivityContext of a worker.
+func WithContext(ctx context.Context, a *Activities) context.Context {
+	return context.WithValue(ctx, contextKey{}, a)
+}
+
+// from returns the Activities of the worker running the activity, a if the worker has none.
+func (a *Activities) from(ctx context.Context) *Activities {
+	if w, ok := ctx.Value(contextKey{}).(*Activities); ok {
+		return w
+	}
+	return a
+}
+
+// requestID names the run and the activity in the requests of an activity, the servers log it with every line.
+func requestID(ctx context.Context) string {
+	info := activity.GetInfo(ctx)
+	return info.WorkflowExecution.RunID + "/" + info.ActivityID
+}
+
+// requestContext sends the request id with the requests to the withdrawal server made within ctx.
+func requestContext(ctx context.Context) context.Context {
+	return apiclient.WithRequestID(ctx, requestID(ctx))
+}
+
+// logger adds the withdrawal to the activity logger, which has the workflow, run and activity type.
+func (a *Activities) logger(ctx context.Context, withdrawalID string) *zap.Logger {
+	logger := activity.GetLogger(ctx)
+	if a.Logger != nil {
+		info := activity.GetInfo(ctx)
+		logger = a.Logger.With(zap.String("WorkflowID", info.WorkflowExecution.ID),
+			zap.String("RunID", info.WorkflowExecution.RunID), zap.String("ActivityType", info.ActivityType.Name))
+	}
+	return logger.With(zap.String("WithdrawalID", withdrawalID))
+}
+
+// WorkflowConfig hands the worker's workflow configuration to a new execution. It runs as a local activity, the
+// recorded result keeps the options of an execution stable while the configuration changes.
+func WorkflowConfig(ctx context.Context) (common.WorkflowConfig, error) {
+	return Default.from(ctx).Workflow, nil
+}
+
+// CreateWithdrawal creates the withdrawal on the withdrawal server.
+func (a *Activities) CreateWithdrawal(ctx context.Context, withdrawalID string) error {
+	a = a.from(ctx)
+	if len(withdrawalID) == 0 {
+		return errors.New("withdrawal id is empty")
+	}
+
+	err := a.Server.Create(requestContext(ctx), withdrawalID, apiclient.Details{})
+	if apiErr, ok := err.(*apiclient.Error); ok && apiErr.Code == apiclient.ErrAlreadyExists {
+		// created with its details before the workflow started, or by an attempt whose reply was lost
+		a.logger(ctx, withdrawalID).Info("Withdrawal exists.")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	a.logger(ctx, withdrawalID).Info("Withdrawal created.")
+	return nil
+}
+
+// WaitForManual waits for the withdrawal decision. This activity will complete asynchronously. When this method
+// returns error activity.ErrResultPending, the cadence client recognize this error, and won't mark this activity
+// as failed or completed. The cadence server will wait until Client.CompleteActivity() is called or timeout happened
+// whichever happen first. In this sample case, the CompleteActivity() method is called by our dummy withdrawal server when
+// the withdrawal is approved.
+func (a *Activities) WaitForManual(ctx context.Context, withdrawalID string) (string, error) {
+	a = a.from(ctx)
+	if len(withdrawalID) == 0 {
+		return "", errors.New("withdrawal id is empty")
+	}
+
+	logger := a.logger(ctx, withdrawalID)
+
+	// save current activity info so it can be completed asynchronously when withdrawal is approved/rejected
+	activityInfo := activity.GetInfo(ctx)
+	err := a.Server.RegisterCallback(requestContext(ctx), withdrawalID, activityInfo.TaskToken)
+	if apiErr, ok := err.(*apiclient.Error); ok {
+		logger.Warn("Register callback failed.", zap.String("WithdrawalStatus", apiErr.Error()))
+		return "", fmt.Errorf("register callback failed status:%s", apiErr)
+	}
+	if err != nil {
+		logger.Info("WaitForManual failed to register callback.", zap.String("Error", httpclient.Message(err)))
+		return "", err
+	}
+
+	// register callback succeed
+	logger.Info("Successfully registered callback.")
+
+	// ErrActivityResultPending is returned from activity's execution to indicate the activity is not completed when it returns.
+	// activity will be completed asynchronously when Client.CompleteActivity() is called.
+	return "", activity.ErrResultPending
+}
+
+func (a *Activities) address(domain string) string {
+	if domain == "sports" {
+		return a.Approvers.Sports
+	}
+	return a.Approvers.Casino
+}
+
+// ApproverPending is the answer of an approver that has not decided yet.
+const ApproverPending = "PENDING"
+
+// ApproverProgress is the heartbeat of WaitForAutomated. Retries resume from it, so the polls and the time an
+// approver takes are counted across attempts.
+type ApproverProgress struct {
+	// Attempt is the activity attempt that recorded the heartbeat, starting at 0.
+	Attempt    int32
+	Polls      int
+	StartedAt  time.Time
+	Elapsed    time.Duration
+	LastStatus string
+}
+
+// WaitForAutomated polls the approver of domain until it approves or rejects. Approvers that take long answer
+// ApproverPending, either right away or after holding the request for up to Approvers.Poll, which is also the
+// least time between two polls. Every poll is recorded as a heartbeat, so slow approvers need a heartbeat timeout
+// rather than a long StartToClose timeout.
+func (a *Activities) WaitForAutomated(ctx context.Context, withdrawalID, domain string) (string, error) {
+	a = a.from(ctx)
+	if len(withdrawalID) == 0 {
+		return "", errors.New("withdrawal id is empty")
+	}
+
+	logger := a.logger(ctx, withdrawalID).With(zap.String("Approver", domain))
+	info := activity.GetInfo(ctx)
+	progress := ApproverProgress{StartedAt: time.Now()}
+	if activity.HasHeartbeatDetails(ctx) {
+		if err := activity.GetHeartbeatDetails(ctx, &progress); err == nil {
+			logger.Info("Resuming approver poll.", zap.Int("Polls", progress.Polls),
+				zap.Duration("Elapsed", progress.Elapsed), zap.String("LastStatus", progress.LastStatus))
+		}
+	}
+	progress.Attempt = info.Attempt
+
+	poll := a.Approvers.Poll
+	target := a.address(domain) + "/?id=" + withdrawalID + "&wait=" + poll.String()
+	for {
+		polled := time.Now()
+		status, err := a.poll(ctx, domain, target)
+		if err != nil {
+			logger.Info("Approver request failed.", zap.String("Error", httpclient.Message(err)))
+			return "", err
+		}
+		if status == "APPROVE" || status == "REJECT" {
+			return status, nil
+		}
+		if status != ApproverPending {
+			logger.Info("Unexpected approver response.", zap.String("WithdrawalStatus", status))
+			// non retryable path
+			return "", cadence.NewCustomError(status)
+		}
+
+		progress.Polls++
+		progress.Elapsed = time.Since(progress.StartedAt)
+		progress.LastStatus = status
+		activity.RecordHeartbeat(ctx, progress)
+
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(poll - time.Since(polled)):
+		}
+	}
+}
+
+// poll asks the approver of domain once.
+func (a *Activities) poll(ctx context.Context, domain, target string) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set(apiclient.RequestIDHeader, requestID(ctx))
+	resp, err := a.HTTP.Do(ctx, domain, req)
+	if err != nil {
+		return "", err
+	}
+	return string(resp.Body), nil
+}
+
+// AutoAction forwards the decision of an automated approver to the withdrawal server.
+func (a *Activities) AutoAction(ctx context.Context, withdrawalID, domain, action string) error {
+	a = a.from(ctx)
+	logger := a.logger(ctx, withdrawalID).With(zap.String("Approver", domain))
+	logger.Info("Forwarding automated decision.", zap.String("Action", action))
+
+	// approve in the system
+	err := a.Server.Decide(requestContext(ctx), withdrawalID, apiclient.Decision{Action: action, Domain: domain})
+	if apiErr, ok := err.(*apiclient.Error); ok {
+		logger.Info("Automated decision failed.", zap.String("WithdrawalStatus", apiErr.Error()))
+	}
+	if err != nil {
+		return err
+	}
+
+	// feedback
+	logger.Info("Automated decision forwarded.")
+	return nil
+}
+
+// GetStatus returns the state of the withdrawal on the withdrawal server.
+func (a *Activities) GetStatus(ctx context.Context, withdrawalID string) (string, error) {
+	a = a.from(ctx)
+	if len(withdrawalID) == 0 {
+		return "", errors.New("withdrawal id is empty")
+	}
+
+	state, err := a.Server.Status(requestContext(ctx), withdrawalID)
+	if apiErr, ok := err.(*apiclient.Error); ok {
+		// executions waiting on the status have always taken error replies for a final status
+		return apiErr.Error(), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return state.String(), nil
+}
+
+// Payment pays out the withdrawal and returns the payout reference.
+func (a *Activities) Payment(ctx context.Context, withdrawalID string) (string, error) {
+	a = a.from(ctx)
+	if len(withdrawalID) == 0 {
+		return "", errors.New("withdrawal id is empty")
+	}
+
+	payoutRef, err := a.Server.Payout(requestContext(ctx), withdrawalID)
+	if err != nil {
+		return "", err
+	}
+	a.logger(ctx, withdrawalID).Info("Payout completed.", zap.String("PayoutRef", payoutRef))
+	return payoutRef, nil
+}
+
+// NotifyCustomer tells the customer about the outcome of their withdrawal on all configured channels. The withdrawal
+// id stands in for the customer of withdrawals created without one.
+func (a *Activities) NotifyCustomer(ctx context.Context, withdrawalID, outcome string) error {
+	a = a.from(ctx)
+	if len(withdrawalID) == 0 {
+		return errors.New("withdrawal id is empty")
+	}
+
+	logger := a.logger(ctx, withdrawalID)
+	notifier, err := notify.New(a.Notify, a.Server.URL("/messages"), logger)
+	if err != nil {
+		return cadence.NewCustomError(err.Error())
+	}
+	if !notifier.Enabled() {
+		return nil
+	}
+
+	record, err := a.Server.Withdrawal(requestContext(ctx), withdrawalID)
+	if err != nil {
+		logger.Info("NotifyCustomer failed.", zap.Error(err))
+		return err
+	}
+	customer := record.Customer
+	if customer == "" {
+		customer = withdrawalID
+	}
+	recipient := notify.Recipient{
+		ID:     customer,
+		Email:  customer + "@" + a.Notify.MailDomain,
+		Locale: a.Notify.Locale,
+	}
+	if err := notifier.Notify(ctx, withdrawalID, outcome, recipient); err != nil {
+		logger.Info("NotifyCustomer failed.", zap.Error(err))
+		return err
+	}
+
+	logger.Info("Customer notified.", zap.String("Outcome", outcome))
+	return nil
+}
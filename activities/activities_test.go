@@ -0,0 +1,89 @@
+package activities
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	apiclient "github.com/bartke/cadence-withdrawal-approval/api/client"
+	"github.com/bartke/cadence-withdrawal-approval/common"
+	"github.com/bartke/cadence-withdrawal-approval/httpclient"
+	"github.com/bartke/cadence-withdrawal-approval/notify"
+	"github.com/bartke/cadence-withdrawal-approval/server"
+	"github.com/bartke/cadence-withdrawal-approval/withdrawal"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+	"go.uber.org/cadence/testsuite"
+	"go.uber.org/cadence/worker"
+)
+
+func TestActivitiesPerWorker(t *testing.T) {
+	status := func(state string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, state)
+		}))
+	}
+	staging, production := status("PENDING"), status("APPROVED")
+	defer staging.Close()
+	defer production.Close()
+
+	// two workers of one process, each with its own server
+	var s testsuite.WorkflowTestSuite
+	for server, expected := range map[string]string{staging.URL: "PENDING", production.URL: "APPROVED"} {
+		env := s.NewTestActivityEnvironment()
+		a := New(common.Configuration{Server: common.ServerConfig{URL: server}, HTTP: httpclient.DefaultConfig()}, nil, nil)
+		env.SetWorkerOptions(worker.Options{BackgroundActivityContext: WithContext(context.Background(), a)})
+
+		result, err := env.ExecuteActivity(Default.GetStatus, "test-withdrawal-id")
+		require.NoError(t, err)
+		var state string
+		require.NoError(t, result.Get(&state))
+		require.Equal(t, expected, state)
+	}
+}
+
+func TestNotifyCustomer(t *testing.T) {
+	srv := httptest.NewServer(server.New(nil, "withdrawalGroup", tally.NoopScope))
+	defer srv.Close()
+	defer delete(withdrawal.DB, "notify-customer")
+	defer delete(withdrawal.DB, "notify-anonymous")
+
+	a := New(common.Configuration{
+		Server:        common.ServerConfig{URL: srv.URL},
+		HTTP:          httpclient.DefaultConfig(),
+		Notifications: notify.Config{Channels: []string{notify.InApp}},
+	}, nil, nil)
+	ctx := context.Background()
+	require.NoError(t, a.Server.Create(ctx, "notify-customer", apiclient.Details{Customer: "c-42"}))
+	require.NoError(t, a.Server.Create(ctx, "notify-anonymous", apiclient.Details{}))
+
+	var s testsuite.WorkflowTestSuite
+	env := s.NewTestActivityEnvironment()
+	env.SetWorkerOptions(worker.Options{BackgroundActivityContext: WithContext(ctx, a)})
+	for _, id := range []string{"notify-customer", "notify-anonymous"} {
+		_, err := env.ExecuteActivity(Default.NotifyCustomer, id, "approved")
+		require.NoError(t, err)
+	}
+
+	// messages are keyed by the customer, the withdrawal id of withdrawals without one
+	messages := func(customer string) []string {
+		resp, err := http.Get(srv.URL + "/messages?customer=" + customer)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var stored []struct {
+			WithdrawalID string `json:"withdrawal_id"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&stored))
+		var ids []string
+		for _, m := range stored {
+			ids = append(ids, m.WithdrawalID)
+		}
+		return ids
+	}
+	require.Equal(t, []string{"notify-customer"}, messages("c-42"))
+	require.Empty(t, messages("notify-customer"))
+	require.Equal(t, []string{"notify-anonymous"}, messages("notify-anonymous"))
+}
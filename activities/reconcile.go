@@ -0,0 +1,69 @@
+package activities
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bartke/cadence-withdrawal-approval/common"
+	"github.com/bartke/cadence-withdrawal-approval/withdrawal"
+	"go.uber.org/cadence"
+	"go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/activity"
+)
+
+// ListWithdrawals returns all withdrawals of the withdrawal server.
+func (a *Activities) ListWithdrawals(ctx context.Context) ([]withdrawal.Record, error) {
+	a = a.from(ctx)
+	return a.Server.Withdrawals(requestContext(ctx))
+}
+
+// ListOpenWithdrawalWorkflows returns the withdrawal ids of all open withdrawal workflows, from visibility.
+// Workflows started before their id was derived from the withdrawal id show up with their random suffix.
+func (a *Activities) ListOpenWithdrawalWorkflows(ctx context.Context) ([]string, error) {
+	a = a.from(ctx)
+	if a.Cadence == nil {
+		return nil, cadence.NewCustomError("cadence client not configured")
+	}
+
+	var ids []string
+	var nextPageToken []byte
+	for {
+		request := &shared.ListOpenWorkflowExecutionsRequest{
+			MaximumPageSize: int32Ptr(1000),
+			NextPageToken:   nextPageToken,
+			StartTimeFilter: &shared.StartTimeFilter{
+				EarliestTime: int64Ptr(0),
+				LatestTime:   int64Ptr(time.Now().UnixNano()),
+			},
+		}
+		resp, err := a.Cadence.ListOpenWorkflow(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+		for _, info := range resp.Executions {
+			workflowID := info.Execution.GetWorkflowId()
+			if strings.HasPrefix(workflowID, common.WorkflowIDPrefix) {
+				ids = append(ids, strings.TrimPrefix(workflowID, common.WorkflowIDPrefix))
+			}
+		}
+		activity.RecordHeartbeat(ctx, len(ids))
+		nextPageToken = resp.NextPageToken
+		if len(nextPageToken) == 0 {
+			break
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// ListPayouts returns the records of the payout provider.
+func (a *Activities) ListPayouts(ctx context.Context) ([]withdrawal.PayoutRecord, error) {
+	a = a.from(ctx)
+	return a.Server.Payouts(requestContext(ctx))
+}
+
+func int32Ptr(v int32) *int32 { return &v }
+
+func int64Ptr(v int64) *int64 { return &v }
@@ -0,0 +1,279 @@
+// Package client is the Go client of the withdrawal server API. Requests go through the shared httpclient, so they
+// get its timeouts and circuit breaker, and ERROR:<code> replies of the server are returned as *Error.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/bartke/cadence-withdrawal-approval/httpclient"
+	"github.com/bartke/cadence-withdrawal-approval/withdrawal"
+)
+
+// Endpoint names the withdrawal server in the httpclient configuration.
+const Endpoint = "server"
+
+// RequestIDHeader carries the id of a request, the servers generate one when it is missing.
+const RequestIDHeader = "X-Request-ID"
+
+// ErrAlreadyExists is the code of the reply to creating a withdrawal whose id is taken.
+const ErrAlreadyExists = "ID_ALREADY_EXISTS"
+
+// Error is an ERROR:<code> reply of the server.
+type Error struct {
+	Code string
+}
+
+func (e *Error) Error() string {
+	return "ERROR:" + e.Code
+}
+
+type (
+	// Details describe a new withdrawal. Both are optional, Amount is a positive decimal.
+	Details struct {
+		Customer string
+		Amount   string
+	}
+
+	// Decision is an approve or reject of an approver. Reviewer and Reason are recorded for manual decisions.
+	Decision struct {
+		// Action is APPROVE or REJECT.
+		Action string
+		// Domain is the approver, sports, casino or manual.
+		Domain   string
+		Reviewer string
+		Reason   string
+	}
+
+	// BulkResult is the result of a single withdrawal of a bulk decision, SUCCEED or an ERROR:<code> reply.
+	BulkResult struct {
+		ID     string `json:"id"`
+		Result string `json:"result"`
+	}
+
+	// ExportOptions selects the format, csv or jsonl, and the dates (YYYY-MM-DD) of an export. Empty dates are open.
+	ExportOptions struct {
+		Format string
+		From   string
+		To     string
+	}
+)
+
+// Client calls the withdrawal server. It is safe for concurrent use.
+type Client struct {
+	url  string
+	http *httpclient.Client
+}
+
+// New returns a client of the server at serverURL that sends its requests with c.
+func New(serverURL string, c *httpclient.Client) *Client {
+	return &Client{url: strings.TrimSuffix(serverURL, "/"), http: c}
+}
+
+// URL returns the url of path on the server.
+func (c *Client) URL(path string) string {
+	return c.url + path
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns a context whose requests carry id in the request id header, the server logs it with every
+// line about the request.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// Create creates a pending withdrawal.
+func (c *Client) Create(ctx context.Context, id string, d Details) error {
+	query := url.Values{"is_api_call": {"true"}, "id": {id}}
+	if d.Customer != "" {
+		query.Set("customer", d.Customer)
+	}
+	if d.Amount != "" {
+		query.Set("amount", d.Amount)
+	}
+	resp, err := c.get(ctx, "/create", query)
+	if err != nil {
+		return err
+	}
+	return succeeded(resp.Body)
+}
+
+// Status returns the state of a withdrawal.
+func (c *Client) Status(ctx context.Context, id string) (withdrawal.State, error) {
+	resp, err := c.get(ctx, "/status", url.Values{"id": {id}})
+	if err != nil {
+		return "", err
+	}
+	if err := replyError(resp.Body); err != nil {
+		return "", err
+	}
+	return withdrawal.State(resp.Body), nil
+}
+
+// Withdrawal describes a withdrawal.
+func (c *Client) Withdrawal(ctx context.Context, id string) (withdrawal.Record, error) {
+	var record withdrawal.Record
+	resp, err := c.get(ctx, "/withdrawal", url.Values{"id": {id}})
+	if err != nil {
+		return record, err
+	}
+	if err := replyError(resp.Body); err != nil {
+		return record, err
+	}
+	if err := json.Unmarshal(resp.Body, &record); err != nil {
+		return record, fmt.Errorf("unexpected reply: %s", resp.Body)
+	}
+	return record, nil
+}
+
+// RegisterCallback hands the server the task token of the activity it completes once the withdrawal is decided.
+func (c *Client) RegisterCallback(ctx context.Context, id string, taskToken []byte) error {
+	resp, err := c.postForm(ctx, "/registerCallback?id="+url.QueryEscape(id), url.Values{"task_token": {string(taskToken)}})
+	if err != nil {
+		return err
+	}
+	return succeeded(resp.Body)
+}
+
+// Decide records the decision of an approver.
+func (c *Client) Decide(ctx context.Context, id string, d Decision) error {
+	query := url.Values{
+		"is_api_call": {"true"},
+		"id":          {id},
+		"type":        {strings.ToLower(d.Action)},
+		"domain":      {d.Domain},
+	}
+	if d.Reviewer != "" {
+		query.Set("reviewer", d.Reviewer)
+	}
+	if d.Reason != "" {
+		query.Set("reason", d.Reason)
+	}
+	resp, err := c.get(ctx, "/action", query)
+	if err != nil {
+		return err
+	}
+	return succeeded(resp.Body)
+}
+
+// Payout pays out an approved withdrawal and returns the payout reference.
+func (c *Client) Payout(ctx context.Context, id string) (string, error) {
+	resp, err := c.get(ctx, "/action", url.Values{"is_api_call": {"true"}, "type": {"payout"}, "id": {id}})
+	if err != nil {
+		return "", err
+	}
+	if err := succeeded(resp.Body); err != nil {
+		return "", err
+	}
+	return resp.Header.Get(withdrawal.PayoutReferenceHeader), nil
+}
+
+// Bulk applies one manual decision to several withdrawals and returns the result of each. Withdrawals that are not
+// pending anymore fail on their own, the others are decided.
+func (c *Client) Bulk(ctx context.Context, ids []string, d Decision) ([]BulkResult, error) {
+	form := url.Values{
+		"id":       ids,
+		"type":     {strings.ToLower(d.Action)},
+		"reviewer": {d.Reviewer},
+		"reason":   {d.Reason},
+	}
+	resp, err := c.postForm(ctx, "/bulk?is_api_call=true", form)
+	if err != nil {
+		return nil, err
+	}
+	if err := replyError(resp.Body); err != nil {
+		return nil, err
+	}
+	var results []BulkResult
+	if err := json.Unmarshal(resp.Body, &results); err != nil {
+		return nil, fmt.Errorf("unexpected reply: %s", resp.Body)
+	}
+	return results, nil
+}
+
+// Export returns the withdrawals created in the range of the options, as csv or json lines.
+func (c *Client) Export(ctx context.Context, o ExportOptions) ([]byte, error) {
+	resp, err := c.get(ctx, "/export", url.Values{"format": {o.Format}, "from": {o.From}, "to": {o.To}})
+	if err != nil {
+		return nil, err
+	}
+	if err := replyError(resp.Body); err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Withdrawals returns all withdrawals.
+func (c *Client) Withdrawals(ctx context.Context) ([]withdrawal.Record, error) {
+	body, err := c.Export(ctx, ExportOptions{Format: "jsonl"})
+	if err != nil {
+		return nil, err
+	}
+	return withdrawal.ReadJSONLines(bytes.NewReader(body))
+}
+
+// Payouts returns the records of the payout provider.
+func (c *Client) Payouts(ctx context.Context) ([]withdrawal.PayoutRecord, error) {
+	resp, err := c.get(ctx, "/payouts", nil)
+	if err != nil {
+		return nil, err
+	}
+	var payouts []withdrawal.PayoutRecord
+	if err := json.Unmarshal(resp.Body, &payouts); err != nil {
+		return nil, fmt.Errorf("unexpected reply: %s", resp.Body)
+	}
+	return payouts, nil
+}
+
+func (c *Client) get(ctx context.Context, path string, query url.Values) (*httpclient.Response, error) {
+	target := c.url + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.do(ctx, req)
+}
+
+func (c *Client) postForm(ctx context.Context, path string, form url.Values) (*httpclient.Response, error) {
+	req, err := http.NewRequest(http.MethodPost, c.url+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return c.do(ctx, req)
+}
+
+func (c *Client) do(ctx context.Context, req *http.Request) (*httpclient.Response, error) {
+	if id, ok := ctx.Value(requestIDKey{}).(string); ok {
+		req.Header.Set(RequestIDHeader, id)
+	}
+	return c.http.Do(ctx, Endpoint, req)
+}
+
+// succeeded checks a reply that is SUCCEED on success.
+func succeeded(body []byte) error {
+	if err := replyError(body); err != nil {
+		return err
+	}
+	if string(body) != "SUCCEED" {
+		return fmt.Errorf("unexpected reply: %s", body)
+	}
+	return nil
+}
+
+// replyError returns the error of an ERROR:<code> reply, nil for any other reply.
+func replyError(body []byte) error {
+	if !bytes.HasPrefix(body, []byte("ERROR:")) {
+		return nil
+	}
+	return &Error{Code: string(bytes.TrimPrefix(body, []byte("ERROR:")))}
+}
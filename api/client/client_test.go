@@ -0,0 +1,86 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bartke/cadence-withdrawal-approval/httpclient"
+	"github.com/bartke/cadence-withdrawal-approval/withdrawal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient(t *testing.T) {
+	var requests []*http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		requests = append(requests, r)
+		switch r.URL.Path {
+		case "/create":
+			if r.Form.Get("id") == "taken" {
+				io.WriteString(w, "ERROR:ID_ALREADY_EXISTS")
+				return
+			}
+			io.WriteString(w, "SUCCEED")
+		case "/withdrawal":
+			json.NewEncoder(w).Encode(withdrawal.Record{ID: r.Form.Get("id"), State: "PENDING", Amount: "25.00"})
+		case "/status":
+			io.WriteString(w, "PENDING")
+		case "/action":
+			w.Header().Set(withdrawal.PayoutReferenceHeader, "PO-"+r.Form.Get("id"))
+			io.WriteString(w, "SUCCEED")
+		case "/bulk":
+			var results []BulkResult
+			for _, id := range r.PostForm["id"] {
+				results = append(results, BulkResult{ID: id, Result: "SUCCEED"})
+			}
+			json.NewEncoder(w).Encode(results)
+		case "/export":
+			io.WriteString(w, `{"id":"w1","state":"PENDING"}`+"\n")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	c := New(server.URL+"/", httpclient.New(httpclient.DefaultConfig(), nil))
+	ctx := WithRequestID(context.Background(), "run/1")
+
+	require.NoError(t, c.Create(ctx, "w1", Details{Customer: "c1", Amount: "25.00"}))
+	require.Equal(t, "run/1", requests[0].Header.Get(RequestIDHeader))
+	require.Equal(t, "c1", requests[0].Form.Get("customer"))
+	require.Equal(t, &Error{Code: ErrAlreadyExists}, c.Create(ctx, "taken", Details{}))
+
+	record, err := c.Withdrawal(ctx, "w1")
+	require.NoError(t, err)
+	require.Equal(t, "25.00", record.Amount)
+
+	state, err := c.Status(ctx, "w1")
+	require.NoError(t, err)
+	require.Equal(t, withdrawal.Pending, state)
+
+	require.NoError(t, c.Decide(ctx, "w1", Decision{Action: "APPROVE", Domain: "sports"}))
+	decide := requests[len(requests)-1].Form
+	require.Equal(t, "approve", decide.Get("type"))
+	require.Equal(t, "sports", decide.Get("domain"))
+
+	ref, err := c.Payout(ctx, "w1")
+	require.NoError(t, err)
+	require.Equal(t, "PO-w1", ref)
+
+	results, err := c.Bulk(ctx, []string{"w1", "w2"}, Decision{Action: "REJECT", Reviewer: "alice", Reason: "fraud"})
+	require.NoError(t, err)
+	require.Equal(t, []BulkResult{{ID: "w1", Result: "SUCCEED"}, {ID: "w2", Result: "SUCCEED"}}, results)
+	require.Equal(t, "alice", requests[len(requests)-1].PostForm.Get("reviewer"))
+
+	records, err := c.Withdrawals(ctx)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	require.Equal(t, "w1", records[0].ID)
+
+	// other 4xx replies are rejected by the http client
+	_, err = c.Payouts(ctx)
+	require.Equal(t, httpclient.ErrRejected+": server: 404 Not Found", httpclient.Message(err))
+}
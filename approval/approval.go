@@ -0,0 +1,220 @@
+// Package approval is step 2 of the withdrawal workflow: it runs the approvers in parallel and decides the
+// withdrawal from their outcomes.
+package approval
+
+import (
+	"github.com/bartke/cadence-withdrawal-approval/activities"
+	"github.com/bartke/cadence-withdrawal-approval/common"
+	"github.com/bartke/cadence-withdrawal-approval/httpclient"
+	"github.com/bartke/cadence-withdrawal-approval/withdrawal"
+	"go.uber.org/cadence"
+	"go.uber.org/cadence/workflow"
+	"go.uber.org/zap"
+)
+
+// Outcome is what a single approver made of a withdrawal.
+type Outcome string
+
+// Approver outcomes.
+const (
+	// OutcomeApproved and OutcomeRejected are decisions.
+	OutcomeApproved Outcome = "APPROVED"
+	OutcomeRejected Outcome = "REJECTED"
+	// OutcomeUnreachable means the approver could not be reached before its activity timed out or ran out of retries.
+	OutcomeUnreachable Outcome = "UNREACHABLE"
+	// OutcomeErrored means the approver answered, but not with a decision.
+	OutcomeErrored Outcome = "ERRORED"
+)
+
+// Approvers are the sources of approver results, in the order their activities are scheduled.
+var Approvers = []string{"sports", "casino", "manual"}
+
+// Undecided is the reason of the error returned when every approver is done and the withdrawal is still pending.
+const Undecided = "UNDECIDED"
+
+// Result is the outcome of one approver, Error is set unless the approver decided.
+type Result struct {
+	Source  string
+	Outcome Outcome
+	Error   string `json:",omitempty"`
+}
+
+// Decided reports whether the approver approved or rejected.
+func (r Result) Decided() bool {
+	return r.Outcome == OutcomeApproved || r.Outcome == OutcomeRejected
+}
+
+// Action is the withdrawal server action for a decision.
+func (r Result) Action() string {
+	if r.Outcome == OutcomeRejected {
+		return "REJECT"
+	}
+	return "APPROVE"
+}
+
+// Classify classifies the result of an approver activity. Automated approvers answer APPROVE or REJECT, the manual
+// review is completed with the state of the withdrawal.
+func Classify(source, status string, err error) Result {
+	r := Result{Source: source}
+	switch {
+	case err == nil && (status == "APPROVE" || status == "APPROVED"):
+		r.Outcome = OutcomeApproved
+	case err == nil && (status == "REJECT" || status == "REJECTED"):
+		r.Outcome = OutcomeRejected
+	case err == nil:
+		r.Outcome = OutcomeErrored
+		r.Error = "unexpected status " + status
+	case isUnavailable(err):
+		r.Outcome = OutcomeUnreachable
+		r.Error = httpclient.Message(err)
+	case cadence.IsCustomError(err):
+		r.Outcome = OutcomeErrored
+		r.Error = httpclient.Message(err)
+	default:
+		// timeouts and transport errors that outlived the retry policy
+		r.Outcome = OutcomeUnreachable
+		r.Error = err.Error()
+	}
+	return r
+}
+
+// isUnavailable reports whether err is an approver that could not be reached until its retries ran out.
+func isUnavailable(err error) bool {
+	customErr, ok := err.(*cadence.CustomError)
+	return ok && customErr.Reason() == httpclient.ErrUnavailable
+}
+
+// Await runs the approvers in parallel and decides the withdrawal with the approval policy as their results
+// come in. Automated decisions are forwarded to the withdrawal server for its records, the manual review is
+// completed by the server and needs no forwarding. Forwarding errors fail the workflow, approvers that are
+// unreachable or errored are recorded and skipped. Outstanding approvers are cancelled once the withdrawal is decided.
+// When ctx is cancelled Await returns the cancellation error with the outcomes received so far.
+func Await(ctx workflow.Context, withdrawalID string, config common.WorkflowConfig) (string, []Result, error) {
+	logger := workflowLogger(ctx, withdrawalID)
+	reviewCtx, cancelReviews := workflow.WithCancel(ctx)
+	defer cancelReviews()
+
+	results := runApprovers(ctx, reviewCtx, withdrawalID, config)
+
+	states := map[string]withdrawal.State{}
+	var outcomes []Result
+	for len(outcomes) < len(Approvers) {
+		r, err := receive(ctx, results)
+		if err != nil {
+			return "", outcomes, err
+		}
+		outcomes = append(outcomes, r)
+		logger.Info("Result received "+r.Source, zap.String("Approver", r.Source), zap.String("Outcome", string(r.Outcome)),
+			zap.String("Error", r.Error))
+		if !r.Decided() {
+			continue
+		}
+		states[r.Source] = withdrawal.State(r.Outcome)
+		if r.Source != "manual" {
+			if err := workflow.ExecuteActivity(ctx, activities.Default.AutoAction, withdrawalID, r.Source, r.Action()).Get(ctx, nil); err != nil {
+				return "", outcomes, err
+			}
+		}
+
+		status := withdrawal.Evaluate(stateOf(states, "sports"), stateOf(states, "casino"), stateOf(states, "manual"))
+		if status != withdrawal.Pending {
+			logger.Info("Status changed "+status.String(), zap.String("WithdrawalStatus", status.String()))
+			return status.String(), outcomes, nil
+		}
+	}
+	return "", outcomes, cadence.NewCustomError(Undecided, outcomes)
+}
+
+// runApprovers starts one coroutine per approver on reviewCtx, with the options configured for the approver. The
+// returned channel is buffered so that approvers never block on a decision nobody waits for anymore. Cancelled
+// approvers send nothing, receive notices the cancellation on ctx instead.
+func runApprovers(ctx, reviewCtx workflow.Context, withdrawalID string, config common.WorkflowConfig) workflow.Channel {
+	results := workflow.NewBufferedChannel(ctx, len(Approvers))
+	for _, source := range Approvers {
+		source := source
+		workflow.Go(reviewCtx, func(ctx workflow.Context) {
+			ctx = workflow.WithActivityOptions(ctx, config.Approver(source).Options())
+			var future workflow.Future
+			if source == "manual" {
+				future = workflow.ExecuteActivity(ctx, activities.Default.WaitForManual, withdrawalID)
+			} else {
+				future = workflow.ExecuteActivity(ctx, activities.Default.WaitForAutomated, withdrawalID, source)
+			}
+			var status string
+			err := future.Get(ctx, &status)
+			if cadence.IsCanceledError(err) {
+				return
+			}
+			results.Send(ctx, Classify(source, status, err))
+		})
+	}
+	return results
+}
+
+// receive waits for the next approver result, or returns the cancellation error once ctx is cancelled.
+func receive(ctx workflow.Context, results workflow.Channel) (Result, error) {
+	var r Result
+	selector := workflow.NewSelector(ctx)
+	selector.AddReceive(results, func(c workflow.Channel, more bool) {
+		c.Receive(ctx, &r)
+	})
+	selector.AddReceive(ctx.Done(), func(c workflow.Channel, more bool) {})
+	selector.Select(ctx)
+	if r.Source == "" {
+		return r, ctx.Err()
+	}
+	return r, nil
+}
+
+func stateOf(states map[string]withdrawal.State, source string) withdrawal.State {
+	if state, ok := states[source]; ok {
+		return state
+	}
+	return withdrawal.Pending
+}
+
+// AwaitPolled is step 2 of executions that entered it at version 2 of the approval change. It runs the approvers in
+// parallel and forwards their decisions to the withdrawal server until the server decides the withdrawal. Like Await
+// it returns the cancellation error once ctx is cancelled.
+func AwaitPolled(ctx workflow.Context, withdrawalID string, config common.WorkflowConfig) (string, []Result, error) {
+	logger := workflowLogger(ctx, withdrawalID)
+	reviewCtx, cancelReviews := workflow.WithCancel(ctx)
+	defer cancelReviews()
+
+	results := runApprovers(ctx, reviewCtx, withdrawalID, config)
+
+	var outcomes []Result
+	var status string
+	for {
+		if err := workflow.ExecuteActivity(ctx, activities.Default.GetStatus, withdrawalID).Get(ctx, &status); err != nil {
+			return "", outcomes, err
+		}
+		if status != "PENDING" {
+			logger.Info("Status changed "+status, zap.String("WithdrawalStatus", status))
+			return status, outcomes, nil
+		}
+		if len(outcomes) == len(Approvers) {
+			return "", outcomes, cadence.NewCustomError(Undecided, outcomes)
+		}
+
+		r, err := receive(ctx, results)
+		if err != nil {
+			return "", outcomes, err
+		}
+		outcomes = append(outcomes, r)
+		logger.Info("Result received "+r.Source, zap.String("Approver", r.Source), zap.String("Outcome", string(r.Outcome)),
+			zap.String("Error", r.Error))
+		// the manual review is completed by the server once it decided, there is nothing to forward
+		if !r.Decided() || r.Source == "manual" {
+			continue
+		}
+		if err := workflow.ExecuteActivity(ctx, activities.Default.AutoAction, withdrawalID, r.Source, r.Action()).Get(ctx, nil); err != nil {
+			return "", outcomes, err
+		}
+	}
+}
+
+// workflowLogger adds the withdrawal to the workflow logger, which has the workflow and run.
+func workflowLogger(ctx workflow.Context, withdrawalID string) *zap.Logger {
+	return workflow.GetLogger(ctx).With(zap.String("WithdrawalID", withdrawalID))
+}
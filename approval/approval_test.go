@@ -0,0 +1,21 @@
+package approval
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bartke/cadence-withdrawal-approval/httpclient"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/cadence"
+)
+
+func TestClassify(t *testing.T) {
+	require.Equal(t, Result{Source: "sports", Outcome: OutcomeApproved}, Classify("sports", "APPROVE", nil))
+	require.Equal(t, Result{Source: "manual", Outcome: OutcomeRejected}, Classify("manual", "REJECTED", nil))
+	require.Equal(t, OutcomeErrored, Classify("casino", "", nil).Outcome)
+	require.Equal(t, OutcomeErrored, Classify("casino", "", cadence.NewCustomError("MAINTENANCE")).Outcome)
+	require.Equal(t, OutcomeUnreachable, Classify("casino", "", errors.New("connection refused")).Outcome)
+	unavailable := Classify("casino", "", cadence.NewCustomError(httpclient.ErrUnavailable, "casino: 503 Service Unavailable"))
+	require.Equal(t, OutcomeUnreachable, unavailable.Outcome)
+	require.Equal(t, "ENDPOINT_UNAVAILABLE: casino: 503 Service Unavailable", unavailable.Error)
+}
@@ -0,0 +1,85 @@
+// Package approver is the sample automated approver that cmd/auto-approver serves. It approves most withdrawals and
+// rejects some, always deciding the same way for the same withdrawal, and can be made slow.
+package approver
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/bartke/cadence-withdrawal-approval/common"
+	"github.com/bartke/cadence-withdrawal-approval/withdrawal"
+	"go.uber.org/zap"
+)
+
+// Approver decides withdrawals on GET /?id=<id>&wait=<duration>.
+type Approver struct {
+	// Seed varies the decisions between approvers, the auto-approver uses its port.
+	Seed string
+	// Delay is how long a decision takes from the first request for a withdrawal. Undecided requests are held for
+	// up to their wait parameter and answered PENDING.
+	Delay time.Duration
+
+	mu sync.Mutex
+	// requested holds when each withdrawal was first requested.
+	requested map[string]time.Time
+}
+
+// New returns an approver seeded with seed that takes delay for a decision.
+func New(seed string, delay time.Duration) *Approver {
+	return &Approver{Seed: seed, Delay: delay, requested: map[string]time.Time{}}
+}
+
+// Decision returns APPROVE or REJECT, what the approver decides for id.
+func (a *Approver) Decision(id string) string {
+	if id != "" && a.hex2rand(id) >= 80 {
+		return string(withdrawal.Reject)
+	}
+	return string(withdrawal.Approve)
+}
+
+func (a *Approver) hex2rand(input string) int {
+	// simple way to make this deterministic for same input
+	seed := int64(int(a.Seed[len(a.Seed)-1])) + int64(int(input[len(input)-1]))
+	return rand.New(rand.NewSource(seed)).Intn(100)
+}
+
+// decidedAt returns when the decision for id is ready.
+func (a *Approver) decidedAt(id string) time.Time {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	at, ok := a.requested[id]
+	if !ok {
+		at = time.Now()
+		a.requested[id] = at
+	}
+	return at.Add(a.Delay)
+}
+
+func (a *Approver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if remaining := time.Until(a.decidedAt(id)); remaining > 0 {
+		wait, _ := time.ParseDuration(r.URL.Query().Get("wait"))
+		if wait < remaining {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(wait):
+			}
+			common.Logger(r.Context()).Debug("Pending.", zap.Duration("Remaining", remaining-wait))
+			w.WriteHeader(http.StatusAccepted)
+			fmt.Fprint(w, "PENDING")
+			return
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(remaining):
+		}
+	}
+
+	result := a.Decision(id)
+	common.Logger(r.Context()).Info("Decided.", zap.String("Action", result))
+	fmt.Fprint(w, result)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/bartke/cadence-withdrawal-approval/approver"
+	"github.com/bartke/cadence-withdrawal-approval/common"
+	"github.com/bartke/cadence-withdrawal-approval/tracing"
+	"go.uber.org/zap"
+)
+
+func main() {
+	var port string
+	var delay time.Duration
+	var traces tracing.Config
+	var logging common.LoggingConfig
+	flag.StringVar(&port, "p", "port", "port to listen on")
+	flag.DurationVar(&delay, "delay", 0, "time a decision takes, undecided requests are held for up to their wait "+
+		"parameter and answered PENDING")
+	flag.StringVar(&logging.Format, "log-format", common.LogConsole, "Log format, console or json.")
+	flag.StringVar(&logging.Level, "log-level", "", "Log level, debug in console and info in json by default.")
+	flag.StringVar(&traces.Exporter, "tracing", "", "Tracing exporter, stdout or zipkin, none by default.")
+	flag.StringVar(&traces.Collector, "collector", "", "Zipkin collector url for the zipkin exporter.")
+	flag.Parse()
+
+	logger, err := common.NewLogger(logging)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer logger.Sync()
+	zap.ReplaceGlobals(logger)
+	logger = logger.With(zap.String("Port", port))
+
+	tracer, closer, err := tracing.New("auto-approval-"+port, traces)
+	if err != nil {
+		logger.Fatal("Failed to set up tracing.", zap.Error(err))
+	}
+	defer closer.Close()
+
+	http.Handle("/", approver.New(port, delay))
+	logger.Info("Starting server.")
+	ctx, stop := common.SignalContext()
+	defer stop()
+	handler := tracing.Middleware(tracer, common.RequestLogging(logger, http.DefaultServeMux))
+	srv := &http.Server{Addr: ":" + port, Handler: handler}
+	if err := common.Serve(ctx, srv, common.DefaultShutdownTimeout); err != nil {
+		logger.Fatal("Server failed.", zap.Error(err))
+	}
+	logger.Info("Stopped server.")
+}
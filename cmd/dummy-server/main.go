@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/bartke/cadence-withdrawal-approval/common"
+	"github.com/bartke/cadence-withdrawal-approval/server"
+	"github.com/bartke/cadence-withdrawal-approval/tracing"
+	"github.com/bartke/cadence-withdrawal-approval/webhook"
+	"github.com/bartke/cadence-withdrawal-approval/withdrawal"
+	"go.uber.org/zap"
+)
+
+func main() {
+	var configFile, logLevel string
+	flag.StringVar(&configFile, "config", "", "Config file, defaults to config/<$WITHDRAWAL_PROFILE or development>.yaml.")
+	flag.StringVar(&logLevel, "log-level", "", "Log level, overrides the configuration.")
+	flag.Parse()
+
+	var h common.SampleHelper
+	if err := h.LoadConfig(configFile); err != nil {
+		log.Fatalln(err)
+	}
+	if logLevel != "" {
+		h.Config.Logging.Level = logLevel
+	}
+	h.SetupServiceConfig()
+	if err := h.SetupTracing("withdrawal-server"); err != nil {
+		h.Logger.Fatal("Failed to set up tracing.", zap.Error(err))
+	}
+	workflowClient, err := h.Builder.BuildCadenceClient()
+	if err != nil {
+		panic(err)
+	}
+	webhook.Register(h.Config.Webhooks...)
+
+	api := server.New(workflowClient, h.Config.TaskList, h.Scope)
+	api.Rules = withdrawal.Rules{SecondApprovalAbove: h.Config.Server.SecondApprovalAbove}
+	mux := http.NewServeMux()
+	// the API needs the domain, it answers ERROR:NOT_READY until the bootstrap is done
+	mux.Handle("/", h.Readiness.Gate(api))
+	mux.Handle("/ready", &h.Readiness)
+	mux.Handle("/metrics", h.MetricsHandler)
+
+	ctx, stop := common.SignalContext()
+	defer stop()
+	bootstrapErr := make(chan error, 1)
+	go func() {
+		if err := h.Bootstrap(ctx); err != nil {
+			if ctx.Err() == nil {
+				// shut the server down gracefully and fail once it stopped
+				bootstrapErr <- fmt.Errorf("bootstrap failed: %v", err)
+				stop()
+			}
+			return
+		}
+		h.Readiness.SetReady()
+		h.Logger.Info("Server is ready.")
+	}()
+
+	h.Logger.Info("Starting server.", zap.String("Listen", h.Config.Server.Listen))
+	handler := tracing.Middleware(h.Tracer, common.RequestLogging(h.Logger, mux))
+	srv := &http.Server{Addr: h.Config.Server.Listen, Handler: handler}
+	err = common.Serve(ctx, srv, h.Config.Timeouts.Shutdown)
+	h.Readiness.SetNotReady(errors.New("stopped"))
+	select {
+	case err = <-bootstrapErr:
+	default:
+	}
+	if err != nil {
+		h.Logger.Error("Server failed.", zap.Error(err))
+		h.Close()
+		os.Exit(1)
+	}
+	h.Logger.Info("Server stopped.")
+	h.Close()
+}
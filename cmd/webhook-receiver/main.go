@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net/http"
+
+	"github.com/bartke/cadence-withdrawal-approval/common"
+	"github.com/bartke/cadence-withdrawal-approval/webhook"
+	"go.uber.org/zap"
+)
+
+var (
+	port    string
+	secret  string
+	failure int
+)
+
+func main() {
+	flag.StringVar(&port, "p", "8098", "port to listen on")
+	flag.StringVar(&secret, "s", "development-secret", "shared secret to verify signatures")
+	flag.IntVar(&failure, "f", 0, "percentage of deliveries to fail, to exercise retries")
+	var logging common.LoggingConfig
+	flag.StringVar(&logging.Format, "log-format", common.LogConsole, "Log format, console or json.")
+	flag.StringVar(&logging.Level, "log-level", "", "Log level, debug in console and info in json by default.")
+	flag.Parse()
+
+	logger, err := common.NewLogger(logging)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer logger.Sync()
+	zap.ReplaceGlobals(logger)
+
+	http.HandleFunc("/", receive)
+	logger.Info("Starting webhook receiver.", zap.String("Port", port))
+	ctx, stop := common.SignalContext()
+	defer stop()
+	srv := &http.Server{Addr: ":" + port, Handler: common.RequestLogging(logger, http.DefaultServeMux)}
+	if err := common.Serve(ctx, srv, common.DefaultShutdownTimeout); err != nil {
+		logger.Fatal("Webhook receiver failed.", zap.Error(err))
+	}
+	logger.Info("Stopped webhook receiver.")
+}
+
+func receive(w http.ResponseWriter, r *http.Request) {
+	logger := common.Logger(r.Context()).With(zap.String("Delivery", r.Header.Get(webhook.DeliveryHeader)))
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !webhook.Verify(secret, body, r.Header.Get(webhook.SignatureHeader)) {
+		logger.Warn("Rejected delivery, invalid signature.")
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	if rand.Intn(100) < failure {
+		logger.Info("Simulating failure.")
+		http.Error(w, "simulated failure", http.StatusServiceUnavailable)
+		return
+	}
+
+	var event webhook.Event
+	if err := json.Unmarshal(body, &event); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	logger.Info("Event received.", zap.String("Event", event.Type), zap.String("WithdrawalID", event.WithdrawalID),
+		zap.String("WithdrawalStatus", event.State), zap.String("Approver", event.Domain))
+}
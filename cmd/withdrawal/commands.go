@@ -0,0 +1,617 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	apiclient "github.com/bartke/cadence-withdrawal-approval/api/client"
+	"github.com/bartke/cadence-withdrawal-approval/common"
+	"github.com/bartke/cadence-withdrawal-approval/httpclient"
+	"github.com/bartke/cadence-withdrawal-approval/loadgen"
+	"github.com/bartke/cadence-withdrawal-approval/workflows"
+	"github.com/pborman/uuid"
+	"go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/client"
+	"go.uber.org/zap"
+)
+
+// cli is the state shared by the commands. The cadence client is only built by the commands that need it, the
+// others just talk to the withdrawal server.
+type cli struct {
+	h      common.SampleHelper
+	out    io.Writer
+	json   bool
+	server *apiclient.Client
+
+	workflowClient client.Client
+}
+
+// command is a subcommand. flags registers its flags and returns the function that runs it with the parsed flags and
+// the remaining arguments.
+type command struct {
+	name    string
+	summary string
+	flags   func(f *flag.FlagSet) func(c *cli, args []string) error
+}
+
+var commands = []command{
+	{"worker", "Run the workflow and activity workers until SIGINT or SIGTERM.", workerCommand},
+	{"trigger", "Start the workflow of a withdrawal, a new one by default.", triggerCommand},
+	{"create", "Create a withdrawal with its customer and amount and start its workflow.", createCommand},
+	{"list", "List the withdrawals of the server.", listCommand},
+	{"describe", "Describe a withdrawal and its workflow.", describeCommand},
+	{"approve", "Approve withdrawals as manual reviewer.", decideCommand("APPROVE")},
+	{"reject", "Reject withdrawals as manual reviewer.", decideCommand("REJECT")},
+	{"payout", "Pay out an approved withdrawal whose workflow closed without paying it.", payoutCommand},
+	{"cancel", "Cancel the workflow of a withdrawal.", cancelCommand},
+	{"terminate", "Terminate a stuck workflow of a withdrawal.", terminateCommand},
+	{"signal", "Send a signal to the workflow of a withdrawal.", signalCommand},
+	{"query", "Query the workflow of a withdrawal, its state by default.", queryCommand},
+	{"history", "Download the history of a withdrawal workflow for the replayer.", historyCommand},
+	{"result", "Print the result of a closed withdrawal workflow.", resultCommand},
+	{"export", "Export the withdrawals of the server as csv or json lines.", exportCommand},
+	{"reconcile", "Schedule the reconciliation workflow.", reconcileCommand},
+	{"load", "Start withdrawals at a steady rate and report throughput and latencies.", loadCommand},
+}
+
+func lookup(name string) *command {
+	for i := range commands {
+		if commands[i].name == name {
+			return &commands[i]
+		}
+	}
+	return nil
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: withdrawal <command> [flags] [ids]")
+	fmt.Fprintln(w)
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run withdrawal <command> -h for the flags of a command.")
+}
+
+// setup sets up logging, metrics and tracing for the commands that talk to cadence.
+func (c *cli) setup(service string) error {
+	if c.h.Service != nil {
+		return nil
+	}
+	c.h.SetupServiceConfig()
+	return c.h.SetupTracing(service)
+}
+
+// workflows returns the cadence client.
+func (c *cli) workflows() (client.Client, error) {
+	if c.workflowClient != nil {
+		return c.workflowClient, nil
+	}
+	if err := c.setup("withdrawal-cli"); err != nil {
+		return nil, err
+	}
+	workflowClient, err := c.h.Builder.BuildCadenceClient()
+	if err != nil {
+		return nil, err
+	}
+	c.workflowClient = workflowClient
+	return workflowClient, nil
+}
+
+// withdrawalID returns the id of the -id flag or the single argument.
+func withdrawalID(id string, args []string) (string, error) {
+	if id == "" && len(args) == 1 {
+		id = args[0]
+	} else if len(args) > 0 {
+		return "", fmt.Errorf("unexpected arguments %v", args)
+	}
+	if id == "" {
+		return "", errors.New("a withdrawal id is required")
+	}
+	return id, nil
+}
+
+func workerCommand(f *flag.FlagSet) func(c *cli, args []string) error {
+	return func(c *cli, args []string) error {
+		if err := c.setup("withdrawal-worker"); err != nil {
+			return err
+		}
+		ctx, stop := common.SignalContext()
+		defer stop()
+		return runWorkers(ctx, &c.h)
+	}
+}
+
+func triggerCommand(f *flag.FlagSet) func(c *cli, args []string) error {
+	var id string
+	f.StringVar(&id, "id", "", "Withdrawal id, defaults to a random UUID. An existing withdrawal keeps its details.")
+	return func(c *cli, args []string) error {
+		if id == "" {
+			id = uuid.New()
+		}
+		return c.start(id)
+	}
+}
+
+// start starts the workflow of a withdrawal and prints its execution.
+func (c *cli) start(withdrawalID string) error {
+	workflowClient, err := c.workflows()
+	if err != nil {
+		return err
+	}
+	execution, err := startWorkflow(context.Background(), workflowClient, c.h.Config, withdrawalID)
+	if err != nil {
+		return err
+	}
+	c.h.Logger.Info("Started Workflow", zap.String("WorkflowID", execution.ID), zap.String("RunID", execution.RunID))
+	started := struct {
+		ID         string `json:"id"`
+		WorkflowID string `json:"workflow_id"`
+		RunID      string `json:"run_id"`
+	}{withdrawalID, execution.ID, execution.RunID}
+	return c.print(started, []string{"ID", "WORKFLOW", "RUN"}, [][]string{{withdrawalID, execution.ID, execution.RunID}})
+}
+
+func createCommand(f *flag.FlagSet) func(c *cli, args []string) error {
+	var id string
+	var details apiclient.Details
+	f.StringVar(&id, "id", "", "Withdrawal id, defaults to a random UUID.")
+	f.StringVar(&details.Customer, "customer", "", "Customer of the withdrawal.")
+	f.StringVar(&details.Amount, "amount", "", "Amount of the withdrawal, a positive decimal.")
+	return func(c *cli, args []string) error {
+		if id == "" {
+			id = uuid.New()
+		}
+		// the workflow finds the withdrawal created and keeps its details
+		if err := c.server.Create(context.Background(), id, details); err != nil {
+			return fmt.Errorf("create failed: %s", httpclient.Message(err))
+		}
+		return c.start(id)
+	}
+}
+
+func listCommand(f *flag.FlagSet) func(c *cli, args []string) error {
+	var state string
+	f.StringVar(&state, "state", "", "Only list withdrawals in this state, e.g. PENDING.")
+	return func(c *cli, args []string) error {
+		records, err := c.server.Withdrawals(context.Background())
+		if err != nil {
+			return err
+		}
+		selected := records[:0]
+		for _, r := range records {
+			if state == "" || strings.EqualFold(r.State, state) {
+				selected = append(selected, r)
+			}
+		}
+		return c.printWithdrawals(selected)
+	}
+}
+
+// workflowInfo is the part of a workflow execution the describe command shows.
+type workflowInfo struct {
+	WorkflowID    string `json:"workflow_id"`
+	RunID         string `json:"run_id"`
+	Status        string `json:"status"`
+	StartTime     string `json:"start_time"`
+	CloseTime     string `json:"close_time,omitempty"`
+	HistoryLength int64  `json:"history_length"`
+}
+
+func describeCommand(f *flag.FlagSet) func(c *cli, args []string) error {
+	var id, runID string
+	f.StringVar(&id, "id", "", "Withdrawal id.")
+	f.StringVar(&runID, "run", "", "Workflow run id, defaults to the latest run.")
+	return func(c *cli, args []string) error {
+		id, err := withdrawalID(id, args)
+		if err != nil {
+			return err
+		}
+		record, err := c.server.Withdrawal(context.Background(), id)
+		if err != nil {
+			return err
+		}
+		workflowClient, err := c.workflows()
+		if err != nil {
+			return err
+		}
+
+		// withdrawals created on the web UI have no workflow
+		var info *workflowInfo
+		resp, err := workflowClient.DescribeWorkflowExecution(context.Background(), common.WorkflowIDPrefix+id, runID)
+		if _, ok := err.(*shared.EntityNotExistsError); !ok && err != nil {
+			return err
+		}
+		if err == nil {
+			execution := resp.WorkflowExecutionInfo
+			info = &workflowInfo{
+				WorkflowID:    execution.Execution.GetWorkflowId(),
+				RunID:         execution.Execution.GetRunId(),
+				Status:        "RUNNING",
+				StartTime:     formatNanos(execution.StartTime),
+				CloseTime:     formatNanos(execution.CloseTime),
+				HistoryLength: execution.GetHistoryLength(),
+			}
+			if execution.CloseStatus != nil {
+				info.Status = execution.CloseStatus.String()
+			}
+		}
+
+		described := struct {
+			Withdrawal interface{}   `json:"withdrawal"`
+			Workflow   *workflowInfo `json:"workflow"`
+		}{record, info}
+		rows := recordFields(record)
+		if info != nil {
+			rows = append(rows,
+				[]string{"workflow", info.WorkflowID},
+				[]string{"run", info.RunID},
+				[]string{"workflow status", info.Status},
+				[]string{"workflow started", info.StartTime},
+				[]string{"workflow closed", orDash(info.CloseTime)},
+				[]string{"history length", fmt.Sprint(info.HistoryLength)},
+			)
+		} else {
+			rows = append(rows, []string{"workflow", "-"})
+		}
+		return c.print(described, nil, rows)
+	}
+}
+
+func decideCommand(action string) func(f *flag.FlagSet) func(c *cli, args []string) error {
+	return func(f *flag.FlagSet) func(c *cli, args []string) error {
+		var id, reviewer, reason string
+		f.StringVar(&id, "id", "", "Withdrawal id, more ids can follow as arguments.")
+		f.StringVar(&reviewer, "reviewer", "", "Reviewer recorded with the decision.")
+		f.StringVar(&reason, "reason", "", "Reason recorded with the decision.")
+		return func(c *cli, args []string) error {
+			ids := args
+			if id != "" {
+				ids = append([]string{id}, args...)
+			}
+			if len(ids) == 0 {
+				return errors.New("a withdrawal id is required")
+			}
+			decision := apiclient.Decision{Action: action, Domain: "manual", Reviewer: reviewer, Reason: reason}
+			results, err := c.server.Bulk(context.Background(), ids, decision)
+			if err != nil {
+				return err
+			}
+			rows := make([][]string, 0, len(results))
+			failed := 0
+			for _, r := range results {
+				if r.Result != "SUCCEED" {
+					failed++
+				}
+				rows = append(rows, []string{r.ID, r.Result})
+			}
+			if err := c.print(results, []string{"ID", "RESULT"}, rows); err != nil {
+				return err
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d of %d withdrawals failed", failed, len(results))
+			}
+			return nil
+		}
+	}
+}
+
+// payoutCommand pays out through the server and bypasses the workflow, which records neither the payout nor a
+// notification. It refuses while the workflow of the withdrawal runs, the workflow pays out itself once approved.
+func payoutCommand(f *flag.FlagSet) func(c *cli, args []string) error {
+	var id string
+	f.StringVar(&id, "id", "", "Withdrawal id.")
+	return func(c *cli, args []string) error {
+		id, err := withdrawalID(id, args)
+		if err != nil {
+			return err
+		}
+		running, err := c.workflowRunning(id)
+		if err != nil {
+			return err
+		}
+		if running {
+			return fmt.Errorf("the workflow of %s is running and pays out itself, cancel or terminate it first", id)
+		}
+		ref, err := c.server.Payout(context.Background(), id)
+		if err != nil {
+			return fmt.Errorf("payout failed: %s", httpclient.Message(err))
+		}
+		paid := struct {
+			ID        string `json:"id"`
+			PayoutRef string `json:"payout_ref"`
+		}{id, ref}
+		return c.print(paid, []string{"ID", "PAYOUT REF"}, [][]string{{id, ref}})
+	}
+}
+
+// workflowRunning reports whether the latest workflow of a withdrawal is still open. Withdrawals created on the web
+// UI have none.
+func (c *cli) workflowRunning(id string) (bool, error) {
+	workflowClient, err := c.workflows()
+	if err != nil {
+		return false, err
+	}
+	resp, err := workflowClient.DescribeWorkflowExecution(context.Background(), common.WorkflowIDPrefix+id, "")
+	if _, ok := err.(*shared.EntityNotExistsError); ok {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return resp.WorkflowExecutionInfo.CloseStatus == nil, nil
+}
+
+func cancelCommand(f *flag.FlagSet) func(c *cli, args []string) error {
+	var id, runID string
+	f.StringVar(&id, "id", "", "Withdrawal id.")
+	f.StringVar(&runID, "run", "", "Workflow run id, defaults to the latest run.")
+	var wait time.Duration
+	f.DurationVar(&wait, "wait", 30*time.Second, "How long to wait for the workflow to close, 0 only requests it.")
+	return func(c *cli, args []string) error {
+		id, err := withdrawalID(id, args)
+		if err != nil {
+			return err
+		}
+		workflowClient, err := c.workflows()
+		if err != nil {
+			return err
+		}
+		workflowID := common.WorkflowIDPrefix + id
+		if err := workflowClient.CancelWorkflow(context.Background(), workflowID, runID); err != nil {
+			return err
+		}
+		if wait <= 0 {
+			return c.done(id, "CANCEL_REQUESTED")
+		}
+
+		// the workflow closes as cancelled unless it was decided before the request reached it
+		ctx, cancel := context.WithTimeout(context.Background(), wait)
+		defer cancel()
+		result, err := closedResult(ctx, workflowClient.GetWorkflow(ctx, workflowID, runID), id)
+		if ctx.Err() != nil {
+			return fmt.Errorf("cancellation of %s requested, its workflow did not close within %s", id, wait)
+		}
+		if err != nil {
+			return err
+		}
+		return c.done(id, result.State)
+	}
+}
+
+func terminateCommand(f *flag.FlagSet) func(c *cli, args []string) error {
+	var id, runID, reason string
+	f.StringVar(&id, "id", "", "Withdrawal id.")
+	f.StringVar(&runID, "run", "", "Workflow run id, defaults to the latest run.")
+	f.StringVar(&reason, "reason", "terminated by operator", "Reason recorded with the termination.")
+	return func(c *cli, args []string) error {
+		id, err := withdrawalID(id, args)
+		if err != nil {
+			return err
+		}
+		workflowClient, err := c.workflows()
+		if err != nil {
+			return err
+		}
+		err = workflowClient.TerminateWorkflow(context.Background(), common.WorkflowIDPrefix+id, runID, reason, nil)
+		if err != nil {
+			return err
+		}
+		return c.done(id, "TERMINATED")
+	}
+}
+
+func signalCommand(f *flag.FlagSet) func(c *cli, args []string) error {
+	var id, runID, name, input string
+	f.StringVar(&id, "id", "", "Withdrawal id.")
+	f.StringVar(&runID, "run", "", "Workflow run id, defaults to the latest run.")
+	f.StringVar(&name, "name", "", "Signal name.")
+	f.StringVar(&input, "input", "", "Signal argument as JSON.")
+	return func(c *cli, args []string) error {
+		id, err := withdrawalID(id, args)
+		if err != nil {
+			return err
+		}
+		if name == "" {
+			return errors.New("a signal name is required")
+		}
+		arg, err := jsonArg(input)
+		if err != nil {
+			return err
+		}
+		workflowClient, err := c.workflows()
+		if err != nil {
+			return err
+		}
+		if err := workflowClient.SignalWorkflow(context.Background(), common.WorkflowIDPrefix+id, runID, name, arg); err != nil {
+			return err
+		}
+		return c.done(id, "SIGNALED")
+	}
+}
+
+func queryCommand(f *flag.FlagSet) func(c *cli, args []string) error {
+	var id, runID, queryType, input string
+	f.StringVar(&id, "id", "", "Withdrawal id.")
+	f.StringVar(&runID, "run", "", "Workflow run id, defaults to the latest run.")
+	f.StringVar(&queryType, "type", workflows.StateQuery, "Query type, __stack_trace for the stack of the workflow.")
+	f.StringVar(&input, "input", "", "Query argument as JSON.")
+	return func(c *cli, args []string) error {
+		id, err := withdrawalID(id, args)
+		if err != nil {
+			return err
+		}
+		var queryArgs []interface{}
+		if input != "" {
+			arg, err := jsonArg(input)
+			if err != nil {
+				return err
+			}
+			queryArgs = append(queryArgs, arg)
+		}
+		workflowClient, err := c.workflows()
+		if err != nil {
+			return err
+		}
+		value, err := workflowClient.QueryWorkflow(context.Background(), common.WorkflowIDPrefix+id, runID, queryType,
+			queryArgs...)
+		if err != nil {
+			return err
+		}
+
+		if queryType == workflows.StateQuery {
+			var result workflows.WithdrawalResult
+			if err := value.Get(&result); err != nil {
+				return err
+			}
+			return c.print(result, nil, resultFields(result))
+		}
+		if queryType == "__stack_trace" {
+			var stack string
+			if err := value.Get(&stack); err != nil {
+				return err
+			}
+			_, err := fmt.Fprintln(c.out, stack)
+			return err
+		}
+		// other queries have no table layout
+		var raw json.RawMessage
+		if err := value.Get(&raw); err != nil {
+			return err
+		}
+		c.json = true
+		return c.print(raw, nil, nil)
+	}
+}
+
+func historyCommand(f *flag.FlagSet) func(c *cli, args []string) error {
+	var id, runID, out string
+	f.StringVar(&id, "id", "", "Withdrawal id.")
+	f.StringVar(&runID, "run", "", "Workflow run id, defaults to the latest run.")
+	f.StringVar(&out, "o", "", "Output file, defaults to stdout.")
+	return func(c *cli, args []string) error {
+		id, err := withdrawalID(id, args)
+		if err != nil {
+			return err
+		}
+		workflowClient, err := c.workflows()
+		if err != nil {
+			return err
+		}
+		return downloadHistory(workflowClient, id, runID, out)
+	}
+}
+
+func resultCommand(f *flag.FlagSet) func(c *cli, args []string) error {
+	var id, runID, out string
+	f.StringVar(&id, "id", "", "Withdrawal id.")
+	f.StringVar(&runID, "run", "", "Workflow run id, defaults to the latest run.")
+	f.StringVar(&out, "o", "", "Output file, defaults to stdout.")
+	return func(c *cli, args []string) error {
+		id, err := withdrawalID(id, args)
+		if err != nil {
+			return err
+		}
+		workflowClient, err := c.workflows()
+		if err != nil {
+			return err
+		}
+		return fetchResult(workflowClient, id, runID, out)
+	}
+}
+
+func exportCommand(f *flag.FlagSet) func(c *cli, args []string) error {
+	var o apiclient.ExportOptions
+	var out string
+	f.StringVar(&o.Format, "format", "csv", "Export format, csv or jsonl.")
+	f.StringVar(&o.From, "from", "", "Export withdrawals created on or after this date (YYYY-MM-DD).")
+	f.StringVar(&o.To, "to", "", "Export withdrawals created on or before this date (YYYY-MM-DD).")
+	f.StringVar(&out, "o", "", "Output file, defaults to stdout.")
+	return func(c *cli, args []string) error {
+		return exportWithdrawals(c.server, o, out)
+	}
+}
+
+func reconcileCommand(f *flag.FlagSet) func(c *cli, args []string) error {
+	var cronSchedule string
+	var repair bool
+	f.StringVar(&cronSchedule, "cron", "0 2 * * *", "Reconciliation cron schedule, empty for a single run.")
+	f.BoolVar(&repair, "repair", false, "Let reconciliation pay out approved withdrawals that are stuck.")
+	return func(c *cli, args []string) error {
+		if err := c.setup("withdrawal-cli"); err != nil {
+			return err
+		}
+		startReconciliation(&c.h, cronSchedule, repair)
+		return nil
+	}
+}
+
+func loadCommand(f *flag.FlagSet) func(c *cli, args []string) error {
+	var config loadgen.Config
+	var amounts string
+	f.Float64Var(&config.Rate, "rate", 1, "Withdrawals started per second, at most 10000.")
+	f.DurationVar(&config.Duration, "duration", time.Minute, "How long withdrawals are started.")
+	f.StringVar(&amounts, "amounts", "lognormal:100:1",
+		"Amount distribution, fixed:<amount>, uniform:<min>:<max> or lognormal:<median>:<sigma>.")
+	f.Float64Var(&config.Approve, "approve", 0.2, "Share of withdrawals the simulated reviewer approves.")
+	f.Float64Var(&config.Reject, "reject", 0.05, "Share of withdrawals the simulated reviewer rejects.")
+	f.DurationVar(&config.Think, "think", 10*time.Second, "Mean time the reviewer takes for a decision.")
+	f.DurationVar(&config.Timeout, "timeout", 10*time.Minute, "How long a withdrawal is followed after its start.")
+	f.DurationVar(&config.Poll, "poll", time.Second, "How often the state of a withdrawal is read.")
+	f.Int64Var(&config.Seed, "seed", 0, "Seed of the amounts and decisions, 0 picks one.")
+	return func(c *cli, args []string) error {
+		distribution, err := loadgen.ParseDistribution(amounts)
+		if err != nil {
+			return err
+		}
+		config.Amounts = distribution
+		if config.Seed == 0 {
+			config.Seed = time.Now().UnixNano()
+		}
+		if err := config.Validate(); err != nil {
+			return err
+		}
+		workflowClient, err := c.workflows()
+		if err != nil {
+			return err
+		}
+		start := func(ctx context.Context, id string, d apiclient.Details) error {
+			if err := c.server.Create(ctx, id, d); err != nil {
+				return err
+			}
+			_, err := startWorkflow(ctx, workflowClient, c.h.Config, id)
+			return err
+		}
+
+		// SIGINT stops the load early and still reports
+		ctx, stop := common.SignalContext()
+		defer stop()
+		c.h.Logger.Info("Generating load.", zap.Float64("Rate", config.Rate), zap.Duration("Duration", config.Duration),
+			zap.Stringer("Amounts", config.Amounts), zap.Int64("Seed", config.Seed))
+		report, err := loadgen.Run(ctx, config, c.server, start, c.h.Logger)
+		if err != nil {
+			return err
+		}
+		if c.json {
+			return c.print(report, nil, nil)
+		}
+		return report.Write(c.out)
+	}
+}
+
+// jsonArg checks a JSON argument of a signal or query, empty is null.
+func jsonArg(input string) (json.RawMessage, error) {
+	if input == "" {
+		return json.RawMessage("null"), nil
+	}
+	if !json.Valid([]byte(input)) {
+		return nil, fmt.Errorf("input is not valid JSON: %s", input)
+	}
+	return json.RawMessage(input), nil
+}
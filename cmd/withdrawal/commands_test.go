@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bartke/cadence-withdrawal-approval/activities"
+	apiclient "github.com/bartke/cadence-withdrawal-approval/api/client"
+	"github.com/bartke/cadence-withdrawal-approval/common"
+	"github.com/bartke/cadence-withdrawal-approval/httpclient"
+	"github.com/bartke/cadence-withdrawal-approval/server"
+	"github.com/bartke/cadence-withdrawal-approval/withdrawal"
+	"github.com/bartke/cadence-withdrawal-approval/workflows"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+	"go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/activity"
+	"go.uber.org/cadence/client"
+	"go.uber.org/cadence/testsuite"
+)
+
+// fakeWorkflows describes the workflows of the withdrawals in running, all others do not exist. Cancellations are
+// sent to cancelled and GetWorkflow returns run.
+type fakeWorkflows struct {
+	client.Client
+	running   map[string]bool
+	cancelled chan<- string
+	run       client.WorkflowRun
+}
+
+func (f *fakeWorkflows) CancelWorkflow(ctx context.Context, workflowID, runID string) error {
+	f.cancelled <- workflowID
+	return nil
+}
+
+func (f *fakeWorkflows) GetWorkflow(ctx context.Context, workflowID, runID string) client.WorkflowRun {
+	return f.run
+}
+
+// fakeRun returns the outcome of the workflow in env once closed is closed.
+type fakeRun struct {
+	client.WorkflowRun
+	env    *testsuite.TestWorkflowEnvironment
+	closed <-chan struct{}
+}
+
+func (r fakeRun) Get(ctx context.Context, valuePtr interface{}) error {
+	select {
+	case <-r.closed:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if err := r.env.GetWorkflowError(); err != nil {
+		return err
+	}
+	return r.env.GetWorkflowResult(valuePtr)
+}
+
+func (f *fakeWorkflows) DescribeWorkflowExecution(ctx context.Context, workflowID, runID string) (
+	*shared.DescribeWorkflowExecutionResponse, error) {
+	running, ok := f.running[workflowID]
+	if !ok {
+		return nil, &shared.EntityNotExistsError{}
+	}
+	info := &shared.WorkflowExecutionInfo{Execution: &shared.WorkflowExecution{WorkflowId: &workflowID}}
+	if !running {
+		status := shared.WorkflowExecutionCloseStatusTerminated
+		info.CloseStatus = &status
+	}
+	return &shared.DescribeWorkflowExecutionResponse{WorkflowExecutionInfo: info}, nil
+}
+
+// newTestCLI returns a cli talking to a withdrawal server on httptest. The withdrawals the test creates are removed
+// from withdrawal.DB on cleanup.
+func newTestCLI(t *testing.T) (*cli, *bytes.Buffer, func()) {
+	existing := map[string]bool{}
+	for id := range withdrawal.DB {
+		existing[id] = true
+	}
+	srv := httptest.NewServer(server.New(nil, "withdrawalGroup", tally.NoopScope))
+	out := &bytes.Buffer{}
+	c := &cli{
+		out:            out,
+		server:         apiclient.New(srv.URL, httpclient.New(httpclient.DefaultConfig(), nil)),
+		workflowClient: &fakeWorkflows{running: map[string]bool{}},
+	}
+	return c, out, func() {
+		srv.Close()
+		for id := range withdrawal.DB {
+			if !existing[id] {
+				delete(withdrawal.DB, id)
+			}
+		}
+	}
+}
+
+// runCommand parses the flags of a command like main does and runs it.
+func runCommand(c *cli, name string, args ...string) error {
+	cmd := lookup(name)
+	if cmd == nil {
+		return errors.New("unknown command " + name)
+	}
+	flags := flag.NewFlagSet("withdrawal "+name, flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	flags.BoolVar(&c.json, "json", false, "")
+	run := cmd.flags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	return run(c, flags.Args())
+}
+
+func TestWithdrawalID(t *testing.T) {
+	id, err := withdrawalID("w1", nil)
+	require.NoError(t, err)
+	require.Equal(t, "w1", id)
+	id, err = withdrawalID("", []string{"w2"})
+	require.NoError(t, err)
+	require.Equal(t, "w2", id)
+
+	_, err = withdrawalID("", nil)
+	require.EqualError(t, err, "a withdrawal id is required")
+	_, err = withdrawalID("w1", []string{"w2"})
+	require.EqualError(t, err, "unexpected arguments [w2]")
+	_, err = withdrawalID("", []string{"w1", "w2"})
+	require.EqualError(t, err, "unexpected arguments [w1 w2]")
+}
+
+func TestJSONArg(t *testing.T) {
+	arg, err := jsonArg("")
+	require.NoError(t, err)
+	require.Equal(t, json.RawMessage("null"), arg)
+	arg, err = jsonArg(`{"reviewer":"alice"}`)
+	require.NoError(t, err)
+	require.Equal(t, json.RawMessage(`{"reviewer":"alice"}`), arg)
+
+	_, err = jsonArg("{reviewer}")
+	require.EqualError(t, err, "input is not valid JSON: {reviewer}")
+}
+
+func TestDecideCommand(t *testing.T) {
+	c, out, cleanup := newTestCLI(t)
+	defer cleanup()
+	ctx := context.Background()
+	require.NoError(t, c.server.Create(ctx, "cli-a", apiclient.Details{}))
+	require.NoError(t, c.server.Create(ctx, "cli-b", apiclient.Details{}))
+
+	require.EqualError(t, runCommand(c, "approve", "-reviewer", "alice"), "a withdrawal id is required")
+
+	err := runCommand(c, "approve", "-reviewer", "alice", "-id", "cli-a", "cli-b", "cli-missing")
+	require.EqualError(t, err, "1 of 3 withdrawals failed")
+	require.Equal(t, "ID           RESULT\n"+
+		"cli-a        SUCCEED\n"+
+		"cli-b        SUCCEED\n"+
+		"cli-missing  ERROR:INVALID_ID\n", out.String())
+
+	out.Reset()
+	err = runCommand(c, "reject", "-json", "cli-a")
+	require.EqualError(t, err, "1 of 1 withdrawals failed")
+	var results []apiclient.BulkResult
+	require.NoError(t, json.Unmarshal(out.Bytes(), &results))
+	require.Equal(t, []apiclient.BulkResult{{ID: "cli-a", Result: "ERROR:INVALID_STATE"}}, results)
+
+	record, err := c.server.Withdrawal(ctx, "cli-b")
+	require.NoError(t, err)
+	require.Equal(t, "APPROVED", record.State)
+	require.Equal(t, "alice", record.ManualReviewer)
+}
+
+func TestPayoutCommand(t *testing.T) {
+	c, out, cleanup := newTestCLI(t)
+	defer cleanup()
+	ctx := context.Background()
+	for _, id := range []string{"cli-running", "cli-closed", "cli-web"} {
+		require.NoError(t, c.server.Create(ctx, id, apiclient.Details{}))
+		require.NoError(t, c.server.Decide(ctx, id, apiclient.Decision{Action: "APPROVE", Domain: "manual"}))
+	}
+	c.workflowClient = &fakeWorkflows{running: map[string]bool{
+		common.WorkflowIDPrefix + "cli-running": true,
+		common.WorkflowIDPrefix + "cli-closed":  false,
+	}}
+
+	err := runCommand(c, "payout", "cli-running")
+	require.EqualError(t, err, "the workflow of cli-running is running and pays out itself, cancel or terminate it first")
+	state, err := c.server.Status(ctx, "cli-running")
+	require.NoError(t, err)
+	require.Equal(t, withdrawal.Approved, state)
+
+	require.NoError(t, runCommand(c, "payout", "cli-closed"))
+	require.Equal(t, "ID          PAYOUT REF\ncli-closed  PO-cli-closed\n", out.String())
+
+	// withdrawals created on the web UI have no workflow
+	out.Reset()
+	require.NoError(t, runCommand(c, "payout", "-json", "-id", "cli-web"))
+	require.JSONEq(t, `{"id":"cli-web","payout_ref":"PO-cli-web"}`, out.String())
+
+	require.EqualError(t, runCommand(c, "payout", "cli-web", "cli-closed"), "unexpected arguments [cli-web cli-closed]")
+}
+
+func TestCancelCommand(t *testing.T) {
+	c, out, cleanup := newTestCLI(t)
+	defer cleanup()
+
+	// a run waiting on the casino and manual approvers
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	env.OnActivity(activities.Default.CreateWithdrawal, mock.Anything, mock.Anything).Return(nil)
+	env.OnActivity(activities.Default.WaitForAutomated, mock.Anything, mock.Anything, "sports").Return("APPROVE", nil)
+	env.OnActivity(activities.Default.WaitForAutomated, mock.Anything, mock.Anything, "casino").
+		Return("", activity.ErrResultPending)
+	env.OnActivity(activities.Default.WaitForManual, mock.Anything, mock.Anything).Return("", activity.ErrResultPending)
+	env.OnActivity(activities.Default.AutoAction, mock.Anything, mock.Anything, "sports", "APPROVE").Return(nil)
+	env.OnActivity(activities.Default.GetStatus, mock.Anything, mock.Anything).Return("PENDING", nil)
+
+	cancelled := make(chan string, 1)
+	closed := make(chan struct{})
+	c.workflowClient = &fakeWorkflows{cancelled: cancelled, run: fakeRun{env: env, closed: closed}}
+	var workflowID string
+	env.RegisterDelayedCallback(func() {
+		workflowID = <-cancelled
+		env.CancelWorkflow()
+	}, time.Minute)
+
+	errs := make(chan error, 1)
+	go func() { errs <- runCommand(c, "cancel", "cli-pending") }()
+	env.ExecuteWorkflow(workflows.SampleWithdrawalWorkflow, "cli-pending")
+	close(closed)
+	require.NoError(t, <-errs)
+	require.Equal(t, common.WorkflowIDPrefix+"cli-pending", workflowID)
+	require.Equal(t, "ID           RESULT\ncli-pending  CANCELLED\n", out.String())
+
+	out.Reset()
+	require.NoError(t, runCommand(c, "cancel", "-wait", "0", "cli-pending"))
+	require.Equal(t, common.WorkflowIDPrefix+"cli-pending", <-cancelled)
+	require.Equal(t, "ID           RESULT\ncli-pending  CANCEL_REQUESTED\n", out.String())
+
+	c.workflowClient = &fakeWorkflows{cancelled: cancelled, run: fakeRun{env: env, closed: make(chan struct{})}}
+	err := runCommand(c, "cancel", "-wait", "10ms", "cli-pending")
+	require.EqualError(t, err, "cancellation of cli-pending requested, its workflow did not close within 10ms")
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	apiclient "github.com/bartke/cadence-withdrawal-approval/api/client"
+)
+
+// exportWithdrawals downloads an export from the withdrawal server into out,
+// or to stdout when out is empty.
+func exportWithdrawals(server *apiclient.Client, o apiclient.ExportOptions, out string) error {
+	body, err := server.Export(context.Background(), o)
+	if err != nil {
+		return fmt.Errorf("export failed: %v", err)
+	}
+
+	var w io.Writer = os.Stdout
+	if out != "" {
+		f, err := os.Create(out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	_, err = w.Write(body)
+	return err
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+
+	"github.com/bartke/cadence-withdrawal-approval/common"
+	"go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/client"
+)
+
+// downloadHistory writes the history of a withdrawal workflow in the json format the cadence CLI produces with
+// `cadence workflow show -of`, which is what the replayer and workflows/testdata/histories expect. An empty runID
+// selects the latest run.
+func downloadHistory(workflowClient client.Client, withdrawalID, runID, out string) error {
+	var events []*shared.HistoryEvent
+	iter := workflowClient.GetWorkflowHistory(context.Background(), common.WorkflowIDPrefix+withdrawalID, runID, false,
+		shared.HistoryEventFilterTypeAllEvent)
+	for iter.HasNext() {
+		event, err := iter.Next()
+		if err != nil {
+			return err
+		}
+		events = append(events, event)
+	}
+
+	data, err := json.MarshalIndent(events, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if out != "" {
+		f, err := os.Create(out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
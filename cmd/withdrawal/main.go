@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bartke/cadence-withdrawal-approval/activities"
+	apiclient "github.com/bartke/cadence-withdrawal-approval/api/client"
+	"github.com/bartke/cadence-withdrawal-approval/common"
+	"github.com/bartke/cadence-withdrawal-approval/httpclient"
+	"github.com/bartke/cadence-withdrawal-approval/tracing"
+	"github.com/bartke/cadence-withdrawal-approval/webhook"
+	"github.com/bartke/cadence-withdrawal-approval/workflows"
+	"go.uber.org/cadence/client"
+	"go.uber.org/cadence/worker"
+	"go.uber.org/cadence/workflow"
+	"go.uber.org/zap"
+)
+
+const (
+	// ApplicationName is the task list for this sample, configs normally use it as tasklist
+	ApplicationName = "withdrawalGroup"
+)
+
+// This needs to be done as part of a bootstrap step when the process starts.
+// The workers are supposed to be long running.
+func startWorkers(h *common.SampleHelper) worker.Worker {
+	cadenceClient, err := h.Builder.BuildCadenceClient()
+	if err != nil {
+		panic(err)
+	}
+	// Configure worker options, stopping waits for running activities up to the shutdown timeout. The activities of
+	// the workers get their dependencies from the background context.
+	workerOptions := worker.Options{
+		MetricsScope:              h.Scope,
+		Logger:                    h.Logger,
+		WorkerStopTimeout:         h.Config.Timeouts.Shutdown,
+		Tracer:                    h.Tracer,
+		ContextPropagators:        []workflow.ContextPropagator{tracing.ActivityPropagator(h.Tracer)},
+		BackgroundActivityContext: activities.WithContext(context.Background(), activities.New(h.Config, cadenceClient, nil)),
+	}
+	webhook.DeadLetterURL = h.Config.Server.URL + "/webhooks/deadletter"
+	webhook.Register(h.Config.Webhooks...)
+	return h.StartWorkers(h.Config.DomainName, h.Config.TaskList, workerOptions)
+}
+
+// runWorkers runs the workers until ctx is done. The readiness and the metrics are served on /ready and /metrics
+// while they run.
+func runWorkers(ctx context.Context, h *common.SampleHelper) error {
+	readiness := make(chan error, 1)
+	if h.Config.Bootstrap.Ready != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/ready", &h.Readiness)
+		mux.Handle("/metrics", h.MetricsHandler)
+		srv := &http.Server{Addr: h.Config.Bootstrap.Ready, Handler: mux}
+		go func() {
+			readiness <- common.Serve(ctx, srv, h.Config.Timeouts.Shutdown)
+		}()
+	} else {
+		readiness <- nil
+	}
+
+	if err := h.Bootstrap(ctx); err != nil {
+		if ctx.Err() != nil {
+			// stopped before the workers started
+			return nil
+		}
+		return fmt.Errorf("bootstrap failed: %v", err)
+	}
+	w := startWorkers(h)
+	h.Readiness.SetReady()
+
+	<-ctx.Done()
+	h.Readiness.SetNotReady(errors.New("stopping"))
+	h.Logger.Info("Stopping workers.", zap.Duration("Timeout", h.Config.Timeouts.Shutdown))
+	w.Stop()
+	if err := <-readiness; err != nil {
+		return fmt.Errorf("readiness endpoint failed: %v", err)
+	}
+	h.Logger.Info("Workers stopped.")
+	return nil
+}
+
+// startWorkflow starts the workflow of a withdrawal.
+func startWorkflow(ctx context.Context, workflowClient client.Client, c common.Configuration, withdrawalID string) (
+	*workflow.Execution, error) {
+	workflowOptions := client.StartWorkflowOptions{
+		ID:                              common.WorkflowIDPrefix + withdrawalID,
+		TaskList:                        c.TaskList,
+		ExecutionStartToCloseTimeout:    c.Timeouts.Workflow,
+		DecisionTaskStartToCloseTimeout: c.Timeouts.DecisionTask,
+	}
+	return workflowClient.StartWorkflow(ctx, workflowOptions, workflows.SampleWithdrawalWorkflow, withdrawalID)
+}
+
+// startReconciliation schedules the reconciliation workflow, each cron run reports independently.
+func startReconciliation(h *common.SampleHelper, cronSchedule string, repair bool) {
+	workflowOptions := client.StartWorkflowOptions{
+		ID:                              "reconciliation",
+		TaskList:                        h.Config.TaskList,
+		ExecutionStartToCloseTimeout:    30 * time.Minute,
+		DecisionTaskStartToCloseTimeout: h.Config.Timeouts.DecisionTask,
+		CronSchedule:                    cronSchedule,
+	}
+	h.StartWorkflow(workflowOptions, workflows.ReconciliationWorkflow, workflows.ReconcileOptions{Repair: repair})
+}
+
+func main() {
+	args := legacyArgs(os.Args[1:])
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "-help" {
+		usage(os.Stderr)
+		os.Exit(2)
+	}
+	cmd := lookup(args[0])
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", args[0])
+		usage(os.Stderr)
+		os.Exit(2)
+	}
+
+	c := &cli{out: os.Stdout}
+	var configFile, logLevel string
+	flags := flag.NewFlagSet("withdrawal "+cmd.name, flag.ExitOnError)
+	flags.StringVar(&configFile, "config", "", "Config file, defaults to config/<$WITHDRAWAL_PROFILE or development>.yaml.")
+	flags.StringVar(&logLevel, "log-level", "", "Log level, overrides the configuration.")
+	flags.BoolVar(&c.json, "json", false, "Print JSON instead of a table.")
+	run := cmd.flags(flags)
+	flags.Parse(args[1:])
+
+	if err := c.h.LoadConfig(configFile); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if logLevel != "" {
+		c.h.Config.Logging.Level = logLevel
+	}
+	c.server = apiclient.New(c.h.Config.Server.URL, httpclient.New(c.h.Config.HTTP, nil))
+
+	err := run(c, flags.Args())
+	c.h.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "withdrawal %s: %s\n", cmd.name, httpclient.Message(err))
+		os.Exit(1)
+	}
+}
+
+// legacyArgs translates the flags of the CLI before it had commands, -m <mode> selects the command and trigger is the
+// default.
+func legacyArgs(args []string) []string {
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		return args
+	}
+	if len(args) == 1 && (args[0] == "-h" || args[0] == "-help") {
+		return args
+	}
+	mode := "trigger"
+	rest := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		switch arg := strings.TrimPrefix(args[i], "-"); {
+		case arg == "m" || arg == "-m":
+			if i+1 < len(args) {
+				mode = args[i+1]
+				i++
+			}
+		case strings.HasPrefix(arg, "m=") || strings.HasPrefix(arg, "-m="):
+			mode = arg[strings.Index(arg, "=")+1:]
+		default:
+			rest = append(rest, args[i])
+		}
+	}
+	return append([]string{mode}, rest...)
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLegacyArgs(t *testing.T) {
+	tests := []struct {
+		args, want []string
+	}{
+		{nil, []string{"trigger"}},
+		{[]string{"list", "-state", "pending"}, []string{"list", "-state", "pending"}},
+		{[]string{"-h"}, []string{"-h"}},
+		{[]string{"-m", "worker"}, []string{"worker"}},
+		{[]string{"-m=trigger", "-id", "w1"}, []string{"trigger", "-id", "w1"}},
+		{[]string{"--m", "payout", "-id", "w1"}, []string{"payout", "-id", "w1"}},
+		{[]string{"-config", "dev.yaml", "--m=worker"}, []string{"worker", "-config", "dev.yaml"}},
+		{[]string{"-id", "w1"}, []string{"trigger", "-id", "w1"}},
+	}
+	for _, test := range tests {
+		require.Equal(t, test.want, legacyArgs(test.args), "%v", test.args)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bartke/cadence-withdrawal-approval/withdrawal"
+	"github.com/bartke/cadence-withdrawal-approval/workflows"
+)
+
+// print writes v as indented JSON with -json, the rows as a table otherwise. A table without header lists the fields
+// of a single item, one per row.
+func (c *cli) print(v interface{}, header []string, rows [][]string) error {
+	if c.json {
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = c.out.Write(append(data, '\n'))
+		return err
+	}
+
+	w := tabwriter.NewWriter(c.out, 0, 4, 2, ' ', 0)
+	if header != nil {
+		fmt.Fprintln(w, strings.Join(header, "\t"))
+	}
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
+
+// done prints the outcome of a command on the workflow of a withdrawal.
+func (c *cli) done(id, result string) error {
+	outcome := struct {
+		ID     string `json:"id"`
+		Result string `json:"result"`
+	}{id, result}
+	return c.print(outcome, []string{"ID", "RESULT"}, [][]string{{id, result}})
+}
+
+func (c *cli) printWithdrawals(records []withdrawal.Record) error {
+	rows := make([][]string, 0, len(records))
+	for _, r := range records {
+		rows = append(rows, []string{
+			r.ID, r.State, orDash(r.Customer), orDash(r.Amount), formatTime(r.CreatedAt), formatTimeOf(r.DecidedAt),
+			orDash(r.PayoutRef),
+		})
+	}
+	return c.print(records, []string{"ID", "STATE", "CUSTOMER", "AMOUNT", "CREATED", "DECIDED", "PAYOUT REF"}, rows)
+}
+
+func recordFields(r withdrawal.Record) [][]string {
+	return [][]string{
+		{"id", r.ID},
+		{"state", r.State},
+		{"customer", orDash(r.Customer)},
+		{"amount", orDash(r.Amount)},
+		{"sports", domainDecision(r.Sports, r.SportsReviewer, r.SportsDecidedAt)},
+		{"casino", domainDecision(r.Casino, r.CasinoReviewer, r.CasinoDecidedAt)},
+		{"manual", domainDecision(r.Manual, r.ManualReviewer, r.ManualDecidedAt)},
+		{"reason", orDash(r.Reason)},
+		{"created", formatTime(r.CreatedAt)},
+		{"decided", formatTimeOf(r.DecidedAt)},
+		{"paid", formatTimeOf(r.PaidAt)},
+		{"payout ref", orDash(r.PayoutRef)},
+	}
+}
+
+func resultFields(r workflows.WithdrawalResult) [][]string {
+	rows := [][]string{
+		{"id", r.WithdrawalID},
+		{"state", orDash(r.State)},
+		{"decided by", orDash(r.DecidedBy)},
+		{"payout ref", orDash(r.PayoutRef)},
+		{"started", formatTime(r.StartedAt)},
+		{"decided", formatTime(r.DecidedAt)},
+		{"closed", formatTime(r.ClosedAt)},
+	}
+	for _, a := range r.Approvers {
+		outcome := string(a.Outcome)
+		if a.Error != "" {
+			outcome += " (" + a.Error + ")"
+		}
+		rows = append(rows, []string{"approver " + a.Source, outcome})
+	}
+	return rows
+}
+
+// domainDecision formats the state of a domain with who decided it when, e.g. "APPROVED by alice at <time>".
+func domainDecision(state, reviewer string, decidedAt *time.Time) string {
+	if reviewer != "" {
+		state += " by " + reviewer
+	}
+	if decidedAt != nil {
+		state += " at " + formatTime(*decidedAt)
+	}
+	return state
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
+
+func formatTimeOf(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return formatTime(*t)
+}
+
+// formatNanos formats the unix nanoseconds cadence reports.
+func formatNanos(nanos *int64) string {
+	if nanos == nil || *nanos == 0 {
+		return ""
+	}
+	return formatTime(time.Unix(0, *nanos))
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/bartke/cadence-withdrawal-approval/approval"
+	"github.com/bartke/cadence-withdrawal-approval/withdrawal"
+	"github.com/bartke/cadence-withdrawal-approval/workflows"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrintWithdrawals(t *testing.T) {
+	created := time.Date(2019, 7, 1, 12, 0, 0, 0, time.UTC)
+	decided := created.Add(90 * time.Second)
+	records := []withdrawal.Record{
+		{ID: "w1", State: "COMPLETED", Customer: "c1", Amount: "25.00", CreatedAt: created, DecidedAt: &decided,
+			PayoutRef: "PO-w1"},
+		{ID: "w2", State: "PENDING", CreatedAt: created},
+	}
+
+	var out bytes.Buffer
+	c := &cli{out: &out}
+	require.NoError(t, c.printWithdrawals(records))
+	require.Equal(t, ""+
+		"ID  STATE      CUSTOMER  AMOUNT  CREATED               DECIDED               PAYOUT REF\n"+
+		"w1  COMPLETED  c1        25.00   2019-07-01T12:00:00Z  2019-07-01T12:01:30Z  PO-w1\n"+
+		"w2  PENDING    -         -       2019-07-01T12:00:00Z  -                     -\n", out.String())
+
+	out.Reset()
+	c.json = true
+	require.NoError(t, c.printWithdrawals(records[1:]))
+	require.Equal(t, "[\n"+
+		"  {\n"+
+		"    \"id\": \"w2\",\n"+
+		"    \"state\": \"PENDING\",\n"+
+		"    \"sports\": \"\",\n"+
+		"    \"casino\": \"\",\n"+
+		"    \"manual\": \"\",\n"+
+		"    \"created_at\": \"2019-07-01T12:00:00Z\"\n"+
+		"  }\n"+
+		"]\n", out.String())
+}
+
+func TestRecordFields(t *testing.T) {
+	created := time.Date(2019, 7, 1, 12, 0, 0, 0, time.UTC)
+	decided := created.Add(time.Minute)
+	r := withdrawal.Record{
+		ID: "w1", State: "REJECTED", Sports: "APPROVED", SportsDecidedAt: &created, Casino: "PENDING",
+		Manual: "REJECTED", ManualReviewer: "alice", ManualDecidedAt: &decided, Reason: "duplicate",
+		CreatedAt: created, DecidedAt: &decided,
+	}
+
+	var out bytes.Buffer
+	c := &cli{out: &out}
+	require.NoError(t, c.print(r, nil, recordFields(r)))
+	require.Equal(t, ""+
+		"id          w1\n"+
+		"state       REJECTED\n"+
+		"customer    -\n"+
+		"amount      -\n"+
+		"sports      APPROVED at 2019-07-01T12:00:00Z\n"+
+		"casino      PENDING\n"+
+		"manual      REJECTED by alice at 2019-07-01T12:01:00Z\n"+
+		"reason      duplicate\n"+
+		"created     2019-07-01T12:00:00Z\n"+
+		"decided     2019-07-01T12:01:00Z\n"+
+		"paid        -\n"+
+		"payout ref  -\n", out.String())
+}
+
+func TestResultFields(t *testing.T) {
+	started := time.Date(2019, 7, 1, 12, 0, 0, 0, time.UTC)
+	r := workflows.WithdrawalResult{
+		WithdrawalID: "w1",
+		State:        "APPROVED",
+		DecidedBy:    workflows.DecidedByAutomated,
+		StartedAt:    started,
+		DecidedAt:    started.Add(time.Minute),
+		Approvers: []approval.Result{
+			{Source: "sports", Outcome: approval.OutcomeApproved},
+			{Source: "casino", Outcome: approval.OutcomeUnreachable, Error: "timeout"},
+		},
+	}
+	require.Equal(t, [][]string{
+		{"id", "w1"},
+		{"state", "APPROVED"},
+		{"decided by", workflows.DecidedByAutomated},
+		{"payout ref", "-"},
+		{"started", "2019-07-01T12:00:00Z"},
+		{"decided", "2019-07-01T12:01:00Z"},
+		{"closed", "-"},
+		{"approver sports", "APPROVED"},
+		{"approver casino", "UNREACHABLE (timeout)"},
+	}, resultFields(r))
+}
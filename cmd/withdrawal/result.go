@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/bartke/cadence-withdrawal-approval/common"
+	"github.com/bartke/cadence-withdrawal-approval/workflows"
+	"go.uber.org/cadence"
+	"go.uber.org/cadence/client"
+)
+
+// fetchResult writes the result of a closed withdrawal workflow as indented json. An empty runID selects the latest
+// run.
+func fetchResult(workflowClient client.Client, withdrawalID, runID, out string) error {
+	ctx := context.Background()
+	workflowID := common.WorkflowIDPrefix + withdrawalID
+	resp, err := workflowClient.DescribeWorkflowExecution(ctx, workflowID, runID)
+	if err != nil {
+		return err
+	}
+	if resp.WorkflowExecutionInfo.CloseStatus == nil {
+		return errors.New("withdrawal " + withdrawalID + " is still in progress")
+	}
+
+	result, err := closedResult(ctx, workflowClient.GetWorkflow(ctx, workflowID, runID), withdrawalID)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if out != "" {
+		f, err := os.Create(out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// closedResult waits for run to close and returns its result. Executions that completed before the workflow returned
+// a WithdrawalResult are reported with the state they returned, "COMPLETED" or empty. Cancelled executions report the
+// result carried by their cancellation error, or just StateCancelled.
+func closedResult(ctx context.Context, run client.WorkflowRun, withdrawalID string) (workflows.WithdrawalResult, error) {
+	var result workflows.WithdrawalResult
+	err := run.Get(ctx, &result)
+	if canceledErr, ok := err.(*cadence.CanceledError); ok {
+		// executions cancelled outside the approval step carry no result
+		if canceledErr.Details(&result) != nil {
+			result = workflows.WithdrawalResult{WithdrawalID: withdrawalID, State: workflows.StateCancelled}
+		}
+		return result, nil
+	}
+	if err != nil {
+		var legacy string
+		if run.Get(ctx, &legacy) != nil {
+			return result, err
+		}
+		return workflows.WithdrawalResult{WithdrawalID: withdrawalID, State: legacy}, nil
+	}
+	return result, nil
+}
@@ -0,0 +1,141 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/client"
+	"go.uber.org/zap"
+)
+
+// maxBootstrapBackoff caps the interval between attempts to reach the cadence frontend.
+const maxBootstrapBackoff = 30 * time.Second
+
+var errStarting = errors.New("starting")
+
+// Readiness is the startup state of a binary, it is not ready until the binary sets it ready after Bootstrap.
+type Readiness struct {
+	mu    sync.RWMutex
+	ready bool
+	err   error
+}
+
+// SetReady marks the binary ready to serve.
+func (r *Readiness) SetReady() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ready, r.err = true, nil
+}
+
+// SetNotReady marks the binary not ready because of err.
+func (r *Readiness) SetNotReady(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ready, r.err = false, err
+}
+
+// Ready returns nil when ready, otherwise the reason it is not.
+func (r *Readiness) Ready() error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.ready {
+		return nil
+	}
+	if r.err != nil {
+		return r.err
+	}
+	return errStarting
+}
+
+// ServeHTTP replies "SUCCEED" when ready and "ERROR:NOT_READY" with status 503 otherwise.
+func (r *Readiness) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if err := r.Ready(); err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprint(w, "ERROR:NOT_READY")
+		return
+	}
+	fmt.Fprint(w, "SUCCEED")
+}
+
+// Gate replies like ServeHTTP until ready and passes requests on to next afterwards.
+func (r *Readiness) Gate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if r.Ready() != nil {
+			r.ServeHTTP(w, req)
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+// Bootstrap waits for the cadence frontend and makes sure the domain exists, registering it when the configuration
+// allows to. A failure is also reported by the readiness.
+func (h *SampleHelper) Bootstrap(ctx context.Context) error {
+	domainClient, err := h.Builder.BuildCadenceDomainClient()
+	if err == nil {
+		err = bootstrapDomain(ctx, domainClient, h.Config, h.Logger)
+	}
+	if err != nil {
+		h.Readiness.SetNotReady(err)
+	}
+	return err
+}
+
+// bootstrapDomain describes the domain until the frontend answers, backing off exponentially for up to
+// Bootstrap.Wait.
+func bootstrapDomain(ctx context.Context, domainClient client.DomainClient, c Configuration, logger *zap.Logger) error {
+	deadline := time.Now().Add(c.Bootstrap.Wait)
+	backoff := c.Bootstrap.Backoff
+	for {
+		_, err := domainClient.Describe(ctx, c.DomainName)
+		switch err.(type) {
+		case nil:
+			logger.Info("Domain is registered.", zap.String("Domain", c.DomainName))
+			return nil
+		case *shared.EntityNotExistsError:
+			return registerDomain(ctx, domainClient, c, logger)
+		}
+
+		if time.Now().Add(backoff).After(deadline) {
+			return fmt.Errorf("cadence frontend %s is not reachable: %v", c.HostNameAndPort, err)
+		}
+		logger.Info("Waiting for cadence frontend.", zap.String("HostPort", c.HostNameAndPort),
+			zap.Duration("Backoff", backoff), zap.Error(err))
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if backoff *= 2; backoff > maxBootstrapBackoff {
+			backoff = maxBootstrapBackoff
+		}
+	}
+}
+
+func registerDomain(ctx context.Context, domainClient client.DomainClient, c Configuration, logger *zap.Logger) error {
+	if !c.Bootstrap.Register {
+		return fmt.Errorf("domain %s does not exist, register it or enable bootstrap.register", c.DomainName)
+	}
+	name, description, emitMetric := c.DomainName, "Withdrawal approval workflows", true
+	retention := c.Bootstrap.Retention
+	err := domainClient.Register(ctx, &shared.RegisterDomainRequest{
+		Name:                                   &name,
+		Description:                            &description,
+		WorkflowExecutionRetentionPeriodInDays: &retention,
+		EmitMetric:                             &emitMetric,
+	})
+	if _, ok := err.(*shared.DomainAlreadyExistsError); ok {
+		// another binary registered it first
+		err = nil
+	}
+	if err != nil {
+		return fmt.Errorf("register domain %s: %v", c.DomainName, err)
+	}
+	logger.Info("Domain registered.", zap.String("Domain", c.DomainName), zap.Int32("RetentionDays", retention))
+	return nil
+}
@@ -0,0 +1,114 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/client"
+	"go.uber.org/zap"
+)
+
+// fakeDomainClient answers Describe with describeErrs in turn, then with success.
+type fakeDomainClient struct {
+	client.DomainClient
+	describeErrs []error
+	registerErr  error
+	describes    int
+	registered   *shared.RegisterDomainRequest
+}
+
+func (f *fakeDomainClient) Describe(ctx context.Context, name string) (*shared.DescribeDomainResponse, error) {
+	f.describes++
+	if len(f.describeErrs) == 0 {
+		return &shared.DescribeDomainResponse{}, nil
+	}
+	err := f.describeErrs[0]
+	f.describeErrs = f.describeErrs[1:]
+	return nil, err
+}
+
+func (f *fakeDomainClient) Register(ctx context.Context, request *shared.RegisterDomainRequest) error {
+	f.registered = request
+	return f.registerErr
+}
+
+func bootstrapConfig(register bool, wait time.Duration) Configuration {
+	return Configuration{
+		DomainName:      "withdrawals-test",
+		HostNameAndPort: "127.0.0.1:7933",
+		Bootstrap:       BootstrapConfig{Register: register, Retention: 3, Wait: wait, Backoff: time.Millisecond},
+	}
+}
+
+func TestBootstrapWaitsForFrontend(t *testing.T) {
+	unreachable := errors.New("connection refused")
+	f := &fakeDomainClient{describeErrs: []error{unreachable, unreachable}}
+	require.NoError(t, bootstrapDomain(context.Background(), f, bootstrapConfig(false, time.Second), zap.NewNop()))
+	require.Equal(t, 3, f.describes)
+	require.Nil(t, f.registered)
+
+	f = &fakeDomainClient{describeErrs: []error{unreachable, unreachable, unreachable}}
+	err := bootstrapDomain(context.Background(), f, bootstrapConfig(false, 0), zap.NewNop())
+	require.EqualError(t, err, "cadence frontend 127.0.0.1:7933 is not reachable: connection refused")
+	require.Equal(t, 1, f.describes)
+}
+
+func TestBootstrapRegistersMissingDomain(t *testing.T) {
+	missing := &shared.EntityNotExistsError{Message: "domain does not exist"}
+
+	f := &fakeDomainClient{describeErrs: []error{missing}}
+	err := bootstrapDomain(context.Background(), f, bootstrapConfig(false, 0), zap.NewNop())
+	require.EqualError(t, err, "domain withdrawals-test does not exist, register it or enable bootstrap.register")
+	require.Nil(t, f.registered)
+
+	f = &fakeDomainClient{describeErrs: []error{missing}}
+	require.NoError(t, bootstrapDomain(context.Background(), f, bootstrapConfig(true, 0), zap.NewNop()))
+	require.Equal(t, "withdrawals-test", f.registered.GetName())
+	require.Equal(t, int32(3), f.registered.GetWorkflowExecutionRetentionPeriodInDays())
+
+	f = &fakeDomainClient{describeErrs: []error{missing}, registerErr: &shared.DomainAlreadyExistsError{}}
+	require.NoError(t, bootstrapDomain(context.Background(), f, bootstrapConfig(true, 0), zap.NewNop()))
+}
+
+func TestReadiness(t *testing.T) {
+	var r Readiness
+	check := func(status int, body string) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ready", nil))
+		require.Equal(t, status, w.Code)
+		require.Equal(t, body, w.Body.String())
+	}
+
+	check(http.StatusServiceUnavailable, "ERROR:NOT_READY")
+	r.SetReady()
+	check(http.StatusOK, "SUCCEED")
+	r.SetNotReady(errors.New("frontend gone"))
+	check(http.StatusServiceUnavailable, "ERROR:NOT_READY")
+	require.EqualError(t, r.Ready(), "frontend gone")
+}
+
+func TestReadinessGate(t *testing.T) {
+	var r Readiness
+	gate := r.Gate(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, "SERVED")
+	}))
+	check := func(status int, body string) {
+		w := httptest.NewRecorder()
+		gate.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/list", nil))
+		require.Equal(t, status, w.Code)
+		require.Equal(t, body, w.Body.String())
+	}
+
+	check(http.StatusServiceUnavailable, "ERROR:NOT_READY")
+	r.SetReady()
+	check(http.StatusOK, "SERVED")
+	r.SetNotReady(errors.New("stopping"))
+	check(http.StatusServiceUnavailable, "ERROR:NOT_READY")
+}
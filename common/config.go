@@ -0,0 +1,356 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	apiclient "github.com/bartke/cadence-withdrawal-approval/api/client"
+	"github.com/bartke/cadence-withdrawal-approval/httpclient"
+	"github.com/bartke/cadence-withdrawal-approval/notify"
+	"github.com/bartke/cadence-withdrawal-approval/tracing"
+	"github.com/bartke/cadence-withdrawal-approval/webhook"
+	"github.com/bartke/cadence-withdrawal-approval/withdrawal"
+	"go.uber.org/cadence"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Profiles, each has a config/<profile>.yaml.
+const (
+	Development = "development"
+	Staging     = "staging"
+	Production  = "production"
+)
+
+// Environment variables selecting the configuration. Every other setting can be overridden with the variables in
+// envOverrides.
+const (
+	ConfigEnv  = "WITHDRAWAL_CONFIG"
+	ProfileEnv = "WITHDRAWAL_PROFILE"
+)
+
+type (
+	// Configuration is shared by the worker, the CLI and the withdrawal server.
+	Configuration struct {
+		Profile         string                 `yaml:"profile"`
+		DomainName      string                 `yaml:"domain"`
+		ServiceName     string                 `yaml:"service"`
+		HostNameAndPort string                 `yaml:"host"`
+		TaskList        string                 `yaml:"tasklist"`
+		Bootstrap       BootstrapConfig        `yaml:"bootstrap"`
+		Server          ServerConfig           `yaml:"server"`
+		Approvers       ApproversConfig        `yaml:"approvers"`
+		HTTP            httpclient.Config      `yaml:"http"`
+		Timeouts        TimeoutsConfig         `yaml:"timeouts"`
+		Workflow        WorkflowConfig         `yaml:"workflow"`
+		Webhooks        []webhook.Subscription `yaml:"webhooks"`
+		Notifications   notify.Config          `yaml:"notifications"`
+		Tracing         tracing.Config         `yaml:"tracing"`
+		Logging         LoggingConfig          `yaml:"logging"`
+	}
+
+	// ServerConfig locates the withdrawal server. URL is where workers and the CLI reach it, Listen is the address
+	// the server binds. Manual approvals of withdrawals above SecondApprovalAbove need a second reviewer, zero
+	// disables the rule.
+	ServerConfig struct {
+		URL                 string  `yaml:"url"`
+		Listen              string  `yaml:"listen"`
+		SecondApprovalAbove float64 `yaml:"second_approval_above"`
+	}
+
+	// ApproversConfig holds the base urls of the automated approval systems. Approvers that have not decided
+	// yet are polled every Poll, approvers that support it hold the request for up to Poll.
+	ApproversConfig struct {
+		Sports string        `yaml:"sports"`
+		Casino string        `yaml:"casino"`
+		Poll   time.Duration `yaml:"poll"`
+	}
+
+	// BootstrapConfig controls the startup of the worker and the server. They wait up to Wait for the cadence
+	// frontend, retrying from Backoff on. With Register a missing domain is registered and keeps the history of
+	// closed workflows for Retention days. The worker serves /ready and /metrics on Ready, empty disables them.
+	BootstrapConfig struct {
+		Register  bool          `yaml:"register"`
+		Retention int32         `yaml:"retention"`
+		Wait      time.Duration `yaml:"wait"`
+		Backoff   time.Duration `yaml:"backoff"`
+		Ready     string        `yaml:"ready"`
+	}
+
+	// TimeoutsConfig bounds withdrawal workflow executions and their decision tasks. Shutdown is how long the
+	// binaries wait for in-flight activities and requests when they stop.
+	TimeoutsConfig struct {
+		Workflow     time.Duration `yaml:"workflow"`
+		DecisionTask time.Duration `yaml:"decisiontask"`
+		Shutdown     time.Duration `yaml:"shutdown"`
+	}
+
+	// RetryPolicy is the yaml form of cadence.RetryPolicy.
+	RetryPolicy struct {
+		InitialInterval          time.Duration `yaml:"initial"`
+		BackoffCoefficient       float64       `yaml:"backoff"`
+		MaximumInterval          time.Duration `yaml:"maximum"`
+		ExpirationInterval       time.Duration `yaml:"expiration"`
+		MaximumAttempts          int32         `yaml:"attempts"`
+		NonRetriableErrorReasons []string      `yaml:"nonretriable"`
+	}
+)
+
+// Addresses returns the address of each automated approver, keyed by its domain. The activities reach an approver
+// through the http endpoint named after its domain.
+func (a ApproversConfig) Addresses() map[string]string {
+	return map[string]string{string(withdrawal.Sports): a.Sports, string(withdrawal.Casino): a.Casino}
+}
+
+// DefaultApproverPoll is the poll interval of the automated approvers when the configuration has none.
+const DefaultApproverPoll = time.Second
+
+// envOverrides are applied after the file is read, in this order.
+var envOverrides = []struct {
+	name string
+	set  func(c *Configuration, v string) error
+}{
+	{"WITHDRAWAL_DOMAIN", func(c *Configuration, v string) error { c.DomainName = v; return nil }},
+	{"WITHDRAWAL_CADENCE_SERVICE", func(c *Configuration, v string) error { c.ServiceName = v; return nil }},
+	{"WITHDRAWAL_CADENCE_HOST", func(c *Configuration, v string) error { c.HostNameAndPort = v; return nil }},
+	{"WITHDRAWAL_TASKLIST", func(c *Configuration, v string) error { c.TaskList = v; return nil }},
+	{"WITHDRAWAL_REGISTER_DOMAIN", func(c *Configuration, v string) error {
+		b, err := strconv.ParseBool(v)
+		c.Bootstrap.Register = b
+		return err
+	}},
+	{"WITHDRAWAL_BOOTSTRAP_WAIT", durationEnv(func(c *Configuration) *time.Duration { return &c.Bootstrap.Wait })},
+	{"WITHDRAWAL_SERVER_URL", func(c *Configuration, v string) error { c.Server.URL = v; return nil }},
+	{"WITHDRAWAL_SERVER_LISTEN", func(c *Configuration, v string) error { c.Server.Listen = v; return nil }},
+	{"WITHDRAWAL_APPROVER_SPORTS", func(c *Configuration, v string) error { c.Approvers.Sports = v; return nil }},
+	{"WITHDRAWAL_APPROVER_CASINO", func(c *Configuration, v string) error { c.Approvers.Casino = v; return nil }},
+	{"WITHDRAWAL_WORKFLOW_TIMEOUT", durationEnv(func(c *Configuration) *time.Duration { return &c.Timeouts.Workflow })},
+	{"WITHDRAWAL_DECISION_TIMEOUT", durationEnv(func(c *Configuration) *time.Duration { return &c.Timeouts.DecisionTask })},
+	{"WITHDRAWAL_LOG_FORMAT", func(c *Configuration, v string) error { c.Logging.Format = v; return nil }},
+	{"WITHDRAWAL_LOG_LEVEL", func(c *Configuration, v string) error { c.Logging.Level = v; return nil }},
+}
+
+func durationEnv(field func(c *Configuration) *time.Duration) func(c *Configuration, v string) error {
+	return func(c *Configuration, v string) error {
+		d, err := time.ParseDuration(v)
+		*field(c) = d
+		return err
+	}
+}
+
+// ConfigPath picks the configuration file: path if set, else $WITHDRAWAL_CONFIG, else the file of the profile in
+// $WITHDRAWAL_PROFILE, development by default. Relative profile files are looked up in the working directory and
+// next to the executable, so the binaries can be started from anywhere.
+func ConfigPath(path string) string {
+	if path != "" {
+		return path
+	}
+	if path = os.Getenv(ConfigEnv); path != "" {
+		return path
+	}
+	profile := os.Getenv(ProfileEnv)
+	if profile == "" {
+		profile = Development
+	}
+	path = filepath.Join("config", profile+".yaml")
+	if !fileExists(path) {
+		if exe, err := os.Executable(); err == nil {
+			if next := filepath.Join(filepath.Dir(exe), path); fileExists(next) {
+				return next
+			}
+		}
+	}
+	return path
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// LoadConfig reads the file selected by ConfigPath, applies the environment overrides and validates the result.
+func LoadConfig(path string) (Configuration, error) {
+	// steps missing in the file keep their defaults
+	c := Configuration{
+		Approvers: ApproversConfig{Poll: DefaultApproverPoll},
+		Timeouts:  TimeoutsConfig{Shutdown: DefaultShutdownTimeout},
+		Workflow:  DefaultWorkflowConfig(),
+		HTTP:      httpclient.DefaultConfig(),
+	}
+	path = ConfigPath(path)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return c, fmt.Errorf("read config: %v", err)
+	}
+	if err := yaml.UnmarshalStrict(data, &c); err != nil {
+		return c, fmt.Errorf("parse config %s: %v", path, err)
+	}
+	for _, o := range envOverrides {
+		v, ok := os.LookupEnv(o.name)
+		if !ok {
+			continue
+		}
+		if err := o.set(&c, v); err != nil {
+			return c, fmt.Errorf("%s: %v", o.name, err)
+		}
+	}
+	if err := c.Validate(); err != nil {
+		return c, fmt.Errorf("invalid config %s: %v", path, err)
+	}
+	return c, nil
+}
+
+// Validate reports every problem of the configuration at once.
+func (c Configuration) Validate() error {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	switch c.Profile {
+	case Development, Staging, Production:
+	default:
+		add("unknown profile %q", c.Profile)
+	}
+	required := []struct{ name, value string }{
+		{"domain", c.DomainName}, {"service", c.ServiceName}, {"host", c.HostNameAndPort},
+		{"tasklist", c.TaskList}, {"server.listen", c.Server.Listen},
+	}
+	for _, f := range required {
+		if f.value == "" {
+			add("%s is required", f.name)
+		}
+	}
+	approvers := c.Approvers.Addresses()
+	domains := make([]string, 0, len(approvers))
+	for domain := range approvers {
+		domains = append(domains, domain)
+	}
+	sort.Strings(domains)
+	if err := validateURL(c.Server.URL); err != nil {
+		add("server.url: %v", err)
+	}
+	for _, domain := range domains {
+		if err := validateURL(approvers[domain]); err != nil {
+			add("approvers.%s: %v", domain, err)
+		}
+	}
+	if c.Server.SecondApprovalAbove < 0 {
+		add("server.second_approval_above must not be negative")
+	}
+	if c.Approvers.Poll <= 0 {
+		add("approvers.poll must be positive")
+	}
+	for _, domain := range domains {
+		if c.Approvers.Poll > 0 && c.Approvers.Poll >= c.HTTP.Endpoint(domain).Timeout {
+			add("approvers.poll must be below the http timeout of %s", domain)
+		}
+	}
+	if err := c.HTTP.Validate(); err != nil {
+		add("http: %v", err)
+	}
+	endpoints := make([]string, 0, len(c.HTTP.Endpoints))
+	for name := range c.HTTP.Endpoints {
+		endpoints = append(endpoints, name)
+	}
+	sort.Strings(endpoints)
+	for _, name := range endpoints {
+		if _, ok := approvers[name]; !ok && name != apiclient.Endpoint {
+			add("http.endpoints.%s: unknown endpoint", name)
+		}
+	}
+	if c.Bootstrap.Register && c.Bootstrap.Retention < 1 {
+		add("bootstrap.retention must be at least one day")
+	}
+	if c.Bootstrap.Wait < 0 {
+		add("bootstrap.wait must not be negative")
+	}
+	if c.Bootstrap.Wait > 0 && c.Bootstrap.Backoff <= 0 {
+		add("bootstrap.backoff must be positive")
+	}
+	if c.Timeouts.Workflow <= 0 {
+		add("timeouts.workflow must be positive")
+	}
+	if c.Timeouts.DecisionTask <= 0 {
+		add("timeouts.decisiontask must be positive")
+	}
+	if c.Timeouts.Shutdown <= 0 {
+		add("timeouts.shutdown must be positive")
+	}
+	if err := c.Workflow.Validate(c.Timeouts.Workflow); err != nil {
+		add("%v", err)
+	}
+	if err := c.Logging.Validate(); err != nil {
+		add("logging: %v", err)
+	}
+	if err := c.Tracing.Validate(); err != nil {
+		add("tracing: %v", err)
+	}
+	for i, s := range c.Webhooks {
+		if s.ID == "" {
+			add("webhooks[%d].id is required", i)
+		}
+		if err := validateURL(s.URL); err != nil {
+			add("webhooks[%d].url: %v", i, err)
+		}
+		if c.Profile == Production && s.Secret == "" {
+			add("webhooks[%d].secret is required in production", i)
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(problems, "; "))
+}
+
+func validateURL(v string) error {
+	if v == "" {
+		return errors.New("is required")
+	}
+	u, err := url.Parse(v)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%q is not an http url", v)
+	}
+	return nil
+}
+
+// Validate checks the policy the way the cadence server would.
+func (p RetryPolicy) Validate() error {
+	switch {
+	case p.InitialInterval <= 0:
+		return errors.New("initial must be positive")
+	case p.BackoffCoefficient < 1:
+		return errors.New("backoff must be at least 1")
+	case p.MaximumInterval != 0 && p.MaximumInterval < p.InitialInterval:
+		return errors.New("maximum must not be below initial")
+	case p.MaximumAttempts < 0:
+		return errors.New("attempts must not be negative")
+	case p.ExpirationInterval <= 0 && p.MaximumAttempts == 0:
+		return errors.New("expiration or attempts is required")
+	}
+	return nil
+}
+
+// CadencePolicy converts the policy for activity options. Requests an endpoint rejected are never retried.
+func (p RetryPolicy) CadencePolicy() *cadence.RetryPolicy {
+	reasons := append([]string{}, p.NonRetriableErrorReasons...)
+	return &cadence.RetryPolicy{
+		InitialInterval:          p.InitialInterval,
+		BackoffCoefficient:       p.BackoffCoefficient,
+		MaximumInterval:          p.MaximumInterval,
+		ExpirationInterval:       p.ExpirationInterval,
+		MaximumAttempts:          p.MaximumAttempts,
+		NonRetriableErrorReasons: append(reasons, httpclient.ErrRejected),
+	}
+}
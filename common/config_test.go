@@ -0,0 +1,74 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	apiclient "github.com/bartke/cadence-withdrawal-approval/api/client"
+	"github.com/bartke/cadence-withdrawal-approval/httpclient"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfigProfiles(t *testing.T) {
+	for _, profile := range []string{Development, Staging, Production} {
+		c, err := LoadConfig(filepath.Join("..", "config", profile+".yaml"))
+		require.NoError(t, err, profile)
+		require.Equal(t, profile, c.Profile)
+		// polled approvers need a heartbeat to notice lost workers
+		for domain := range c.Approvers.Addresses() {
+			require.NotZero(t, c.Workflow.Approver(domain).Heartbeat, "%s %s", profile, domain)
+		}
+	}
+}
+
+func TestLoadConfigEnvOverrides(t *testing.T) {
+	os.Setenv("WITHDRAWAL_SERVER_URL", "http://withdrawals.internal:8099")
+	os.Setenv("WITHDRAWAL_WORKFLOW_TIMEOUT", "2h")
+	defer os.Unsetenv("WITHDRAWAL_SERVER_URL")
+	defer os.Unsetenv("WITHDRAWAL_WORKFLOW_TIMEOUT")
+
+	c, err := LoadConfig(filepath.Join("..", "config", "development.yaml"))
+	require.NoError(t, err)
+	require.Equal(t, "http://withdrawals.internal:8099", c.Server.URL)
+	require.Equal(t, 2*time.Hour, c.Timeouts.Workflow)
+
+	os.Setenv("WITHDRAWAL_WORKFLOW_TIMEOUT", "soon")
+	_, err = LoadConfig(filepath.Join("..", "config", "development.yaml"))
+	require.Error(t, err)
+}
+
+func TestValidate(t *testing.T) {
+	c, err := LoadConfig(filepath.Join("..", "config", "development.yaml"))
+	require.NoError(t, err)
+
+	c.Profile = "qa"
+	c.Approvers.Casino = "localhost:8092"
+	c.Timeouts.Workflow = 0
+	c.Workflow.Create.Retry.BackoffCoefficient = 0.5
+	err = c.Validate()
+	require.EqualError(t, err, `unknown profile "qa"; approvers.casino: "localhost:8092" is not an http url; `+
+		`timeouts.workflow must be positive; workflow.create: retry: backoff must be at least 1`)
+
+	// steps have to fit into the workflow
+	c, _ = LoadConfig(filepath.Join("..", "config", "development.yaml"))
+	c.Timeouts.Workflow = 90 * time.Minute
+	require.EqualError(t, c.Validate(), "workflow.approvers.casino: retry expiration 2h0m0s exceeds timeouts.workflow "+
+		"1h30m0s; workflow.approvers.sports: retry expiration 2h0m0s exceeds timeouts.workflow 1h30m0s")
+	c.Timeouts.Workflow = 30 * time.Minute
+	c.Workflow.Approvers = map[string]ActivityConfig{"manual": c.Workflow.Approvers["manual"]}
+	require.EqualError(t, c.Validate(), "workflow.approvers.manual: start 1h0m0s exceeds timeouts.workflow 30m0s")
+
+	c, _ = LoadConfig(filepath.Join("..", "config", "development.yaml"))
+	c.Profile = Production
+	c.Webhooks[0].Secret = ""
+	require.EqualError(t, c.Validate(), "webhooks[0].secret is required in production")
+
+	c, _ = LoadConfig(filepath.Join("..", "config", "development.yaml"))
+	c.HTTP.Endpoints = map[string]httpclient.EndpointConfig{
+		apiclient.Endpoint: {}, "casino": {}, "sports": {Cooldown: -time.Second}, "payments": {}, "manual": {},
+	}
+	require.EqualError(t, c.Validate(), "http: endpoints.sports: cooldown must be positive; "+
+		"http.endpoints.manual: unknown endpoint; http.endpoints.payments: unknown endpoint")
+}
@@ -4,22 +4,22 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"io/ioutil"
+	"io"
+	"net/http"
 
 	"go.uber.org/cadence/worker"
 	"go.uber.org/yarpc"
 	"go.uber.org/yarpc/transport/tchannel"
 	"go.uber.org/zap"
 
+	"github.com/bartke/cadence-withdrawal-approval/tracing"
+	opentracing "github.com/opentracing/opentracing-go"
 	"github.com/uber-go/tally"
 	"go.uber.org/cadence/.gen/go/cadence/workflowserviceclient"
 	"go.uber.org/cadence/client"
-	yaml "gopkg.in/yaml.v2"
 )
 
 const (
-	configFile = "config/development.yaml"
-
 	cadenceClientName      = "cadence-client"
 	cadenceFrontendService = "cadence-frontend"
 )
@@ -32,15 +32,30 @@ type (
 		Logger  *zap.Logger
 		Config  Configuration
 		Builder *WorkflowClientBuilder
+		// Tracer is the noop tracer until SetupTracing.
+		Tracer opentracing.Tracer
+		// MetricsHandler serves the metrics of Scope to prometheus.
+		MetricsHandler http.Handler
+		// Readiness is set ready by the binaries once they serve, see Bootstrap.
+		Readiness Readiness
+
+		configLoaded bool
+		scopeCloser  io.Closer
+		tracerCloser io.Closer
 	}
+)
 
-	// Configuration for running samples.
-	Configuration struct {
-		DomainName      string `yaml:"domain"`
-		ServiceName     string `yaml:"service"`
-		HostNameAndPort string `yaml:"host"`
+// LoadConfig loads the configuration from path, see ConfigPath for the defaults. SetupServiceConfig loads the
+// default configuration unless LoadConfig was called before.
+func (h *SampleHelper) LoadConfig(path string) error {
+	config, err := LoadConfig(path)
+	if err != nil {
+		return err
 	}
-)
+	h.Config = config
+	h.configLoaded = true
+	return nil
+}
 
 // SetupServiceConfig setup the config for the sample code run
 func (h *SampleHelper) SetupServiceConfig() {
@@ -48,25 +63,22 @@ func (h *SampleHelper) SetupServiceConfig() {
 		return
 	}
 
-	// Initialize developer config for running samples
-	configData, err := ioutil.ReadFile(configFile)
-	if err != nil {
-		panic(fmt.Sprintf("Failed to log config file: %v, Error: %v", configFile, err))
-	}
-
-	if err := yaml.Unmarshal(configData, &h.Config); err != nil {
-		panic(fmt.Sprintf("Error initializing configuration: %v", err))
+	if !h.configLoaded {
+		if err := h.LoadConfig(""); err != nil {
+			panic(fmt.Sprintf("Error initializing configuration: %v", err))
+		}
 	}
 
-	// Initialize logger for running samples
-	logger, err := zap.NewDevelopment()
+	logger, err := NewLogger(h.Config.Logging)
 	if err != nil {
-		panic(err)
+		panic(fmt.Sprintf("Error initializing logger: %v", err))
 	}
+	zap.ReplaceGlobals(logger)
 
-	logger.Info("Logger created.")
+	logger.Info("Logger created.", zap.String("Profile", h.Config.Profile))
 	h.Logger = logger
-	h.Scope = tally.NoopScope
+	h.Scope, h.MetricsHandler, h.scopeCloser = newMetricsScope()
+	h.Tracer = opentracing.NoopTracer{}
 	h.Builder = NewBuilder(logger).
 		SetHostPort(h.Config.HostNameAndPort).
 		SetDomain(h.Config.DomainName).
@@ -76,14 +88,6 @@ func (h *SampleHelper) SetupServiceConfig() {
 		panic(err)
 	}
 	h.Service = service
-
-	domainClient, _ := h.Builder.BuildCadenceDomainClient()
-	_, err = domainClient.Describe(context.Background(), h.Config.DomainName)
-	if err != nil {
-		logger.Info("Domain doesn't exist", zap.String("Domain", h.Config.DomainName), zap.Error(err))
-	} else {
-		logger.Info("Domain succeesfully registered.", zap.String("Domain", h.Config.DomainName))
-	}
 }
 
 // StartWorkflow starts a workflow
@@ -104,14 +108,42 @@ func (h *SampleHelper) StartWorkflow(options client.StartWorkflowOptions, workfl
 	}
 }
 
-// StartWorkers starts workflow worker and activity worker based on configured options.
-func (h *SampleHelper) StartWorkers(domainName, groupName string, options worker.Options) {
+// StartWorkers starts workflow worker and activity worker based on configured options. Stop the returned worker
+// to shut them down.
+func (h *SampleHelper) StartWorkers(domainName, groupName string, options worker.Options) worker.Worker {
 	worker := worker.New(h.Service, domainName, groupName, options)
 	err := worker.Start()
 	if err != nil {
 		h.Logger.Error("Failed to start workers.", zap.Error(err))
 		panic("Failed to start workers")
 	}
+	return worker
+}
+
+// SetupTracing traces the binary as service with the configured exporter, after SetupServiceConfig. Cadence clients
+// built afterwards trace the workflows they start.
+func (h *SampleHelper) SetupTracing(service string) error {
+	tracer, closer, err := tracing.New(service, h.Config.Tracing)
+	if err != nil {
+		return err
+	}
+	h.Tracer, h.tracerCloser = tracer, closer
+	opentracing.SetGlobalTracer(tracer)
+	h.Builder.SetTracer(tracer)
+	return nil
+}
+
+// Close flushes the logger, the spans and the metrics before the process exits.
+func (h *SampleHelper) Close() {
+	if h.tracerCloser != nil {
+		h.tracerCloser.Close()
+	}
+	if h.scopeCloser != nil {
+		h.scopeCloser.Close()
+	}
+	if h.Logger != nil {
+		h.Logger.Sync()
+	}
 }
 
 // WorkflowClientBuilder build client to cadence service
@@ -121,6 +153,7 @@ type WorkflowClientBuilder struct {
 	domain         string
 	clientIdentity string
 	metricsScope   tally.Scope
+	tracer         opentracing.Tracer
 	Logger         *zap.Logger
 }
 
@@ -149,6 +182,12 @@ func (b *WorkflowClientBuilder) SetMetricsScope(metricsScope tally.Scope) *Workf
 	return b
 }
 
+// SetTracer sets the tracer for the builder
+func (b *WorkflowClientBuilder) SetTracer(tracer opentracing.Tracer) *WorkflowClientBuilder {
+	b.tracer = tracer
+	return b
+}
+
 // BuildCadenceClient builds a client to cadence service
 func (b *WorkflowClientBuilder) BuildCadenceClient() (client.Client, error) {
 	service, err := b.BuildServiceClient()
@@ -157,7 +196,7 @@ func (b *WorkflowClientBuilder) BuildCadenceClient() (client.Client, error) {
 	}
 
 	return client.NewClient(
-		service, b.domain, &client.Options{Identity: b.clientIdentity, MetricsScope: b.metricsScope}), nil
+		service, b.domain, &client.Options{Identity: b.clientIdentity, MetricsScope: b.metricsScope, Tracer: b.tracer}), nil
 }
 
 // BuildCadenceDomainClient builds a domain client to cadence service
@@ -0,0 +1,107 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	apiclient "github.com/bartke/cadence-withdrawal-approval/api/client"
+	"github.com/pborman/uuid"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Log formats.
+const (
+	LogJSON    = "json"
+	LogConsole = "console"
+)
+
+// LoggingConfig selects the log format, console by default, and the minimum level, info in json and debug in
+// console by default.
+type LoggingConfig struct {
+	Format string `yaml:"format"`
+	Level  string `yaml:"level"`
+}
+
+// NewLogger builds the logger of a binary.
+func NewLogger(c LoggingConfig) (*zap.Logger, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	zc := zap.NewDevelopmentConfig()
+	if c.Format == LogJSON {
+		zc = zap.NewProductionConfig()
+	}
+	if c.Level != "" {
+		zc.Level.UnmarshalText([]byte(c.Level))
+	}
+	return zc.Build()
+}
+
+// Validate checks the format and the level.
+func (c LoggingConfig) Validate() error {
+	switch c.Format {
+	case LogJSON, LogConsole, "":
+	default:
+		return fmt.Errorf("unknown format %q", c.Format)
+	}
+	if c.Level == "" {
+		return nil
+	}
+	var level zapcore.Level
+	return level.UnmarshalText([]byte(c.Level))
+}
+
+type loggerKey struct{}
+
+// WithLogger returns a context carrying logger.
+func WithLogger(ctx context.Context, logger *zap.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// Logger returns the logger of ctx, the global zap logger if it has none.
+func Logger(ctx context.Context) *zap.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*zap.Logger); ok {
+		return logger
+	}
+	return zap.L()
+}
+
+// RequestLogging gives every request an id and a logger with the id, the withdrawal and the approver domain of the
+// request. Handlers get the logger with Logger(r.Context()), every request is logged once served.
+func RequestLogging(logger *zap.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(apiclient.RequestIDHeader)
+		if id == "" {
+			id = uuid.New()
+		}
+		w.Header().Set(apiclient.RequestIDHeader, id)
+
+		fields := []zap.Field{zap.String("RequestID", id)}
+		if withdrawalID := r.URL.Query().Get("id"); withdrawalID != "" {
+			fields = append(fields, zap.String("WithdrawalID", withdrawalID))
+		}
+		if domain := r.URL.Query().Get("domain"); domain != "" {
+			fields = append(fields, zap.String("Approver", domain))
+		}
+		requestLogger := logger.With(fields...)
+
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r.WithContext(WithLogger(r.Context(), requestLogger)))
+		requestLogger.Info("Request served.", zap.String("Method", r.Method), zap.String("Path", r.URL.Path),
+			zap.Int("Status", sw.status), zap.Duration("Duration", time.Since(start)))
+	})
+}
+
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,55 @@
+package common
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	apiclient "github.com/bartke/cadence-withdrawal-approval/api/client"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	zapobserver "go.uber.org/zap/zaptest/observer"
+)
+
+func TestRequestLogging(t *testing.T) {
+	core, logs := zapobserver.New(zap.DebugLevel)
+	handler := RequestLogging(zap.New(core), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Logger(r.Context()).Info("Handled.")
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/action?id=w1&domain=sports", nil)
+	req.Header.Set(apiclient.RequestIDHeader, "run/1")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, "run/1", rec.Header().Get(apiclient.RequestIDHeader))
+
+	entries := logs.AllUntimed()
+	require.Len(t, entries, 2)
+	for _, e := range entries {
+		fields := e.ContextMap()
+		require.Equal(t, "run/1", fields["RequestID"])
+		require.Equal(t, "w1", fields["WithdrawalID"])
+		require.Equal(t, "sports", fields["Approver"])
+	}
+	require.Equal(t, "Handled.", entries[0].Message)
+	require.Equal(t, int64(http.StatusTeapot), entries[1].ContextMap()["Status"])
+
+	// a request without an id gets one
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/list", nil))
+	require.NotEmpty(t, rec.Header().Get(apiclient.RequestIDHeader))
+	require.NotContains(t, logs.AllUntimed()[3].ContextMap(), "WithdrawalID")
+}
+
+func TestLoggingConfig(t *testing.T) {
+	require.NoError(t, LoggingConfig{}.Validate())
+	require.NoError(t, LoggingConfig{Format: LogJSON, Level: "warn"}.Validate())
+	require.Error(t, LoggingConfig{Format: "text"}.Validate())
+	require.Error(t, LoggingConfig{Level: "loud"}.Validate())
+
+	logger, err := NewLogger(LoggingConfig{Format: LogJSON, Level: "warn"})
+	require.NoError(t, err)
+	require.False(t, logger.Core().Enabled(zap.InfoLevel))
+	require.True(t, logger.Core().Enabled(zap.WarnLevel))
+}
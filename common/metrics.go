@@ -0,0 +1,202 @@
+package common
+
+import (
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/uber-go/tally"
+)
+
+// metricsInterval is how often the scopes report to prometheus.
+const metricsInterval = time.Second
+
+// prometheusNames restricts metric names and tag keys to what prometheus accepts, the cadence client uses dashes.
+var prometheusNames = tally.SanitizeOptions{
+	NameCharacters:       tally.ValidCharacters{Ranges: tally.AlphanumericRange, Characters: tally.UnderscoreCharacters},
+	KeyCharacters:        tally.ValidCharacters{Ranges: tally.AlphanumericRange, Characters: tally.UnderscoreCharacters},
+	ValueCharacters:      tally.ValidCharacters{Ranges: tally.AlphanumericRange, Characters: tally.UnderscoreDashDotCharacters},
+	ReplacementCharacter: tally.DefaultReplacementCharacter,
+}
+
+// newMetricsScope creates the root scope of a binary and the handler prometheus scrapes it on. Closing the scope
+// reports the last values.
+func newMetricsScope() (tally.Scope, http.Handler, io.Closer) {
+	reporter := newPrometheusReporter()
+	scope, closer := tally.NewRootScope(tally.ScopeOptions{
+		CachedReporter:  reporter,
+		Separator:       "_",
+		SanitizeOptions: &prometheusNames,
+	}, metricsInterval)
+	return scope, promhttp.HandlerFor(reporter.registry, promhttp.HandlerOpts{}), closer
+}
+
+// prometheusReporter is a tally reporter that keeps the metrics in a prometheus registry. Every name gets one
+// vector, metrics reported under a known name with other tag keys are dropped since prometheus rejects them.
+type prometheusReporter struct {
+	registry *prometheus.Registry
+
+	mu      sync.Mutex
+	vectors map[string]prometheusVector
+}
+
+type prometheusVector struct {
+	keys      []string
+	collector prometheus.Collector
+}
+
+func newPrometheusReporter() *prometheusReporter {
+	return &prometheusReporter{registry: prometheus.NewRegistry(), vectors: map[string]prometheusVector{}}
+}
+
+func (r *prometheusReporter) Capabilities() tally.Capabilities { return r }
+func (r *prometheusReporter) Reporting() bool                  { return true }
+func (r *prometheusReporter) Tagging() bool                    { return true }
+func (r *prometheusReporter) Flush()                           {}
+
+// vector returns the collector of name, creating it with newVector on first use. It returns nil when name is
+// known with other tag keys.
+func (r *prometheusReporter) vector(name string, tags map[string]string,
+	newVector func(keys []string) prometheus.Collector) (prometheus.Collector, prometheus.Labels) {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	v, ok := r.vectors[name]
+	if !ok {
+		v = prometheusVector{keys: keys, collector: newVector(keys)}
+		if err := r.registry.Register(v.collector); err != nil {
+			return nil, nil
+		}
+		r.vectors[name] = v
+	}
+	if !sameKeys(v.keys, keys) {
+		return nil, nil
+	}
+	return v.collector, prometheus.Labels(tags)
+}
+
+func sameKeys(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *prometheusReporter) AllocateCounter(name string, tags map[string]string) tally.CachedCount {
+	c, labels := r.vector(name, tags, func(keys []string) prometheus.Collector {
+		return prometheus.NewCounterVec(prometheus.CounterOpts{Name: name, Help: name}, keys)
+	})
+	if c == nil {
+		return noopMetric{}
+	}
+	return counter{c.(*prometheus.CounterVec).With(labels)}
+}
+
+func (r *prometheusReporter) AllocateGauge(name string, tags map[string]string) tally.CachedGauge {
+	c, labels := r.vector(name, tags, func(keys []string) prometheus.Collector {
+		return prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: name, Help: name}, keys)
+	})
+	if c == nil {
+		return noopMetric{}
+	}
+	return gauge{c.(*prometheus.GaugeVec).With(labels)}
+}
+
+func (r *prometheusReporter) AllocateTimer(name string, tags map[string]string) tally.CachedTimer {
+	c, labels := r.vector(name, tags, func(keys []string) prometheus.Collector {
+		return prometheus.NewSummaryVec(prometheus.SummaryOpts{Name: name, Help: name}, keys)
+	})
+	if c == nil {
+		return noopMetric{}
+	}
+	return observer{c.(*prometheus.SummaryVec).With(labels)}
+}
+
+// AllocateHistogram keeps the buckets of tally. Tally reports counts per bucket, the samples are observed at the
+// upper bound of their bucket, so the sum of the histogram is an estimate.
+func (r *prometheusReporter) AllocateHistogram(name string, tags map[string]string,
+	buckets tally.Buckets) tally.CachedHistogram {
+	_, durations := buckets.(tally.DurationBuckets)
+	c, labels := r.vector(name, tags, func(keys []string) prometheus.Collector {
+		var bounds []float64
+		if durations {
+			for _, d := range buckets.AsDurations() {
+				bounds = append(bounds, d.Seconds())
+			}
+		} else {
+			bounds = buckets.AsValues()
+		}
+		return prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: name, Help: name, Buckets: bounds}, keys)
+	})
+	if c == nil {
+		return noopMetric{}
+	}
+	return histogram{c.(*prometheus.HistogramVec).With(labels)}
+}
+
+type counter struct{ prometheus.Counter }
+
+func (c counter) ReportCount(value int64) { c.Add(float64(value)) }
+
+type gauge struct{ prometheus.Gauge }
+
+func (g gauge) ReportGauge(value float64) { g.Set(value) }
+
+type observer struct{ prometheus.Observer }
+
+func (o observer) ReportTimer(interval time.Duration) { o.Observe(interval.Seconds()) }
+
+type histogram struct{ prometheus.Observer }
+
+func (h histogram) ValueBucket(lower, upper float64) tally.CachedHistogramBucket {
+	if upper == math.MaxFloat64 {
+		// the overflow bucket, just above its lower bound keeps the sum finite
+		upper = math.Nextafter(lower, math.Inf(1))
+	}
+	return histogramBucket{h.Observer, upper}
+}
+
+func (h histogram) DurationBucket(lower, upper time.Duration) tally.CachedHistogramBucket {
+	if upper == time.Duration(math.MaxInt64) {
+		return histogramBucket{h.Observer, math.Nextafter(lower.Seconds(), math.Inf(1))}
+	}
+	return histogramBucket{h.Observer, upper.Seconds()}
+}
+
+type histogramBucket struct {
+	prometheus.Observer
+	value float64
+}
+
+func (b histogramBucket) ReportSamples(value int64) {
+	for i := int64(0); i < value; i++ {
+		b.Observe(b.value)
+	}
+}
+
+// noopMetric stands in for metrics prometheus rejected.
+type noopMetric struct{}
+
+func (noopMetric) ReportCount(int64)                                        {}
+func (noopMetric) ReportGauge(float64)                                      {}
+func (noopMetric) ReportTimer(time.Duration)                                {}
+func (noopMetric) ValueBucket(float64, float64) tally.CachedHistogramBucket { return noopMetric{} }
+func (noopMetric) DurationBucket(time.Duration, time.Duration) tally.CachedHistogramBucket {
+	return noopMetric{}
+}
+func (noopMetric) ReportSamples(int64) {}
@@ -0,0 +1,33 @@
+package common
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+)
+
+func TestMetricsScope(t *testing.T) {
+	scope, handler, closer := newMetricsScope()
+	scope.Tagged(map[string]string{"approver": "sports"}).Counter("cadence-decisions").Inc(2)
+	// prometheus rejects a known name with other labels, it is dropped
+	scope.Tagged(map[string]string{"outcome": "APPROVED"}).Counter("cadence-decisions").Inc(1)
+	scope.Gauge("manual_queue_depth").Update(3)
+	buckets := tally.DurationBuckets{time.Second, time.Minute}
+	scope.Histogram("time_to_decision", buckets).RecordDuration(30 * time.Second)
+	scope.Histogram("time_to_decision", buckets).RecordDuration(time.Hour)
+	require.NoError(t, closer.Close())
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := w.Body.String()
+	require.Contains(t, body, `cadence_decisions{approver="sports"} 2`)
+	require.NotContains(t, body, `outcome="APPROVED"`)
+	require.Contains(t, body, "manual_queue_depth 3")
+	require.Contains(t, body, `time_to_decision_bucket{le="1"} 0`)
+	require.Contains(t, body, `time_to_decision_bucket{le="60"} 1`)
+	require.Contains(t, body, `time_to_decision_bucket{le="+Inf"} 2`)
+}
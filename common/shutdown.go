@@ -0,0 +1,51 @@
+package common
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// DefaultShutdownTimeout bounds the shutdown of binaries without a configuration.
+const DefaultShutdownTimeout = 10 * time.Second
+
+// SignalContext returns a context that is canceled on SIGINT or SIGTERM, the signal is logged to the global logger.
+func SignalContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		defer signal.Stop(signals)
+		select {
+		case s := <-signals:
+			zap.L().Info("Shutting down.", zap.Stringer("Signal", s))
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// Serve runs srv until ctx is done and then gives in-flight requests up to timeout to finish. It returns nil after a
+// clean shutdown.
+func Serve(ctx context.Context, srv *http.Server, timeout time.Duration) error {
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return srv.Shutdown(shutdownCtx)
+}
@@ -0,0 +1,23 @@
+package common
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestServe(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- Serve(ctx, &http.Server{Addr: "127.0.0.1:0"}, time.Second)
+	}()
+	cancel()
+	require.NoError(t, <-done)
+
+	err := Serve(context.Background(), &http.Server{Addr: "127.0.0.1:-1"}, time.Second)
+	require.Error(t, err)
+}
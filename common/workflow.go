@@ -0,0 +1,167 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/cadence/workflow"
+)
+
+// WorkflowIDPrefix prefixes the withdrawal id in withdrawal workflow ids.
+const WorkflowIDPrefix = "withdrawal_"
+
+type (
+	// WorkflowConfig holds the activity options of every step of the withdrawal workflow. Executions capture it
+	// once when they start, changes apply to executions started afterwards.
+	WorkflowConfig struct {
+		Create   ActivityConfig `yaml:"create"`
+		Approval ActivityConfig `yaml:"approval"`
+		Payout   ActivityConfig `yaml:"payout"`
+		Notify   ActivityConfig `yaml:"notify"`
+		// Approvers override Approval for single approvers, keyed sports, casino and manual.
+		Approvers map[string]ActivityConfig `yaml:"approvers"`
+	}
+
+	// ActivityConfig is the yaml form of workflow.ActivityOptions. Without Retry activities are not retried.
+	ActivityConfig struct {
+		ScheduleToStart time.Duration `yaml:"schedule"`
+		StartToClose    time.Duration `yaml:"start"`
+		Heartbeat       time.Duration `yaml:"heartbeat"`
+		Retry           *RetryPolicy  `yaml:"retry"`
+	}
+)
+
+// DefaultWorkflowConfig are the options the workflow used before they were configurable. Executions started before
+// then keep using them.
+func DefaultWorkflowConfig() WorkflowConfig {
+	return WorkflowConfig{
+		Create: ActivityConfig{
+			ScheduleToStart: time.Minute,
+			StartToClose:    time.Minute,
+			Heartbeat:       20 * time.Second,
+			Retry: &RetryPolicy{
+				InitialInterval:          time.Second,
+				BackoffCoefficient:       2.0,
+				MaximumInterval:          time.Minute,
+				ExpirationInterval:       5 * time.Minute,
+				MaximumAttempts:          10,
+				NonRetriableErrorReasons: []string{},
+			},
+		},
+		Approval: ActivityConfig{
+			ScheduleToStart: 10 * time.Minute,
+			StartToClose:    10 * time.Minute,
+			Retry: &RetryPolicy{
+				InitialInterval:          time.Second,
+				BackoffCoefficient:       2.0,
+				MaximumInterval:          time.Minute,
+				ExpirationInterval:       5 * time.Minute,
+				MaximumAttempts:          10,
+				NonRetriableErrorReasons: []string{"DISAPPROVED", "disapproved", "REJECT", "rejected"},
+			},
+		},
+		Payout: ActivityConfig{
+			ScheduleToStart: 10 * time.Minute,
+			StartToClose:    10 * time.Minute,
+		},
+		Notify: ActivityConfig{
+			ScheduleToStart: time.Minute,
+			StartToClose:    time.Minute,
+			Retry: &RetryPolicy{
+				InitialInterval:    time.Second,
+				BackoffCoefficient: 2.0,
+				MaximumInterval:    time.Minute,
+				ExpirationInterval: 10 * time.Minute,
+				MaximumAttempts:    3,
+			},
+		},
+	}
+}
+
+// Approver returns the options for one approver.
+func (c WorkflowConfig) Approver(source string) ActivityConfig {
+	if a, ok := c.Approvers[source]; ok {
+		return a
+	}
+	return c.Approval
+}
+
+// Validate reports every problem of the workflow configuration at once, named by their path in the config file.
+// Steps must fit into executions of the workflow timeout, a step that outlasts them would be cut off.
+func (c WorkflowConfig) Validate(workflowTimeout time.Duration) error {
+	var problems []string
+	check := func(name string, a ActivityConfig) {
+		if err := a.Validate(); err != nil {
+			problems = append(problems, "workflow."+name+": "+err.Error())
+			return
+		}
+		if workflowTimeout <= 0 {
+			return
+		}
+		if a.StartToClose > workflowTimeout {
+			problems = append(problems, fmt.Sprintf("workflow.%s: start %s exceeds timeouts.workflow %s",
+				name, a.StartToClose, workflowTimeout))
+		}
+		if a.Retry != nil && a.Retry.ExpirationInterval > workflowTimeout {
+			problems = append(problems, fmt.Sprintf("workflow.%s: retry expiration %s exceeds timeouts.workflow %s",
+				name, a.Retry.ExpirationInterval, workflowTimeout))
+		}
+	}
+	check("create", c.Create)
+	check("approval", c.Approval)
+	check("payout", c.Payout)
+	check("notify", c.Notify)
+
+	sources := make([]string, 0, len(c.Approvers))
+	for source := range c.Approvers {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	for _, source := range sources {
+		switch source {
+		case "sports", "casino", "manual":
+			check("approvers."+source, c.Approvers[source])
+		default:
+			problems = append(problems, "workflow.approvers."+source+": unknown approver")
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(problems, "; "))
+}
+
+// Validate checks the timeouts and the retry policy.
+func (a ActivityConfig) Validate() error {
+	switch {
+	case a.ScheduleToStart <= 0:
+		return errors.New("schedule must be positive")
+	case a.StartToClose <= 0:
+		return errors.New("start must be positive")
+	case a.Heartbeat < 0:
+		return errors.New("heartbeat must not be negative")
+	}
+	if a.Retry != nil {
+		if err := a.Retry.Validate(); err != nil {
+			return fmt.Errorf("retry: %v", err)
+		}
+	}
+	return nil
+}
+
+// Options converts the configuration to activity options.
+func (a ActivityConfig) Options() workflow.ActivityOptions {
+	ao := workflow.ActivityOptions{
+		ScheduleToStartTimeout: a.ScheduleToStart,
+		StartToCloseTimeout:    a.StartToClose,
+		HeartbeatTimeout:       a.Heartbeat,
+	}
+	if a.Retry != nil {
+		ao.RetryPolicy = a.Retry.CadencePolicy()
+	}
+	return ao
+}
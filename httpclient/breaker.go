@@ -0,0 +1,54 @@
+package httpclient
+
+import (
+	"sync"
+	"time"
+)
+
+// breaker is the circuit of one endpoint. It opens after consecutive failures, rejects requests for the cooldown
+// and then lets a single trial request through: a success closes it, a failure opens it for another cooldown.
+type breaker struct {
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	trial     bool
+}
+
+// allow reports whether a request may be sent. A true result must be followed by record or release.
+func (b *breaker) allow(c EndpointConfig, now time.Time) bool {
+	if c.Failures == 0 {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < c.Failures {
+		return true
+	}
+	if now.Before(b.openUntil) || b.trial {
+		return false
+	}
+	b.trial = true
+	return true
+}
+
+// record counts the outcome of a request.
+func (b *breaker) record(c EndpointConfig, ok bool, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+	if ok {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if c.Failures > 0 && b.failures >= c.Failures {
+		b.openUntil = now.Add(c.Cooldown)
+	}
+}
+
+// release ends a request without an outcome, e.g. one the caller cancelled.
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+}
@@ -0,0 +1,218 @@
+// Package httpclient is the HTTP client the activities share. Every endpoint has its own timeout and circuit
+// breaker, and failed calls are returned as cadence custom errors: ErrUnavailable when a retry may succeed,
+// ErrRejected when it cannot.
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/cadence"
+)
+
+// Reasons of the errors returned by the client, the details are a message naming the endpoint and the cause.
+const (
+	// ErrUnavailable is the reason used when the endpoint could not be reached, timed out, answered 5xx, 408 or
+	// 429, or its circuit is open. Retrying may succeed.
+	ErrUnavailable = "ENDPOINT_UNAVAILABLE"
+	// ErrRejected is the reason used when the endpoint answered with any other 4xx; retrying cannot fix that.
+	ErrRejected = "ENDPOINT_REJECTED"
+)
+
+type (
+	// Config holds the settings of all endpoints and overrides for single endpoints. Fields left out of an
+	// override keep the value of the defaults.
+	Config struct {
+		EndpointConfig `yaml:",inline"`
+		Endpoints      map[string]EndpointConfig `yaml:"endpoints"`
+	}
+
+	// EndpointConfig bounds every request to an endpoint by Timeout. After Failures consecutive failures the
+	// circuit of the endpoint opens and requests fail right away, after Cooldown a single trial request decides
+	// whether it closes again. Failures 0 disables the breaker.
+	EndpointConfig struct {
+		Timeout  time.Duration `yaml:"timeout"`
+		Failures int           `yaml:"failures"`
+		Cooldown time.Duration `yaml:"cooldown"`
+	}
+)
+
+// DefaultConfig is used when the configuration has no http section.
+func DefaultConfig() Config {
+	return Config{EndpointConfig: EndpointConfig{Timeout: 10 * time.Second, Failures: 5, Cooldown: 30 * time.Second}}
+}
+
+// Endpoint returns the settings of an endpoint.
+func (c Config) Endpoint(name string) EndpointConfig {
+	e := c.EndpointConfig
+	o, ok := c.Endpoints[name]
+	if !ok {
+		return e
+	}
+	if o.Timeout != 0 {
+		e.Timeout = o.Timeout
+	}
+	if o.Failures != 0 {
+		e.Failures = o.Failures
+	}
+	if o.Cooldown != 0 {
+		e.Cooldown = o.Cooldown
+	}
+	return e
+}
+
+// Validate reports every problem at once, the settings of single endpoints are named by their path.
+func (c Config) Validate() error {
+	var problems []string
+	if err := c.EndpointConfig.Validate(); err != nil {
+		problems = append(problems, err.Error())
+	}
+	names := make([]string, 0, len(c.Endpoints))
+	for name := range c.Endpoints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := c.Endpoint(name).Validate(); err != nil {
+			problems = append(problems, "endpoints."+name+": "+err.Error())
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(problems, "; "))
+}
+
+// Validate checks the timeout and the breaker.
+func (e EndpointConfig) Validate() error {
+	switch {
+	case e.Timeout <= 0:
+		return errors.New("timeout must be positive")
+	case e.Failures < 0:
+		return errors.New("failures must not be negative")
+	case e.Failures > 0 && e.Cooldown <= 0:
+		return errors.New("cooldown must be positive")
+	}
+	return nil
+}
+
+// Response is a response that was read in full.
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// Client sends requests to named endpoints. It is safe for concurrent use.
+type Client struct {
+	config Config
+	client *http.Client
+	now    func() time.Time
+
+	mu       sync.Mutex
+	breakers map[string]*breaker
+}
+
+// New returns a client for the configuration, transport nil uses http.DefaultTransport.
+func New(c Config, transport http.RoundTripper) *Client {
+	return &Client{
+		config:   c,
+		client:   &http.Client{Transport: transport},
+		now:      time.Now,
+		breakers: map[string]*breaker{},
+	}
+}
+
+// Get sends a GET request to the endpoint.
+func (c *Client) Get(ctx context.Context, endpoint, target string) (*Response, error) {
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		return nil, cadence.NewCustomError(ErrRejected, err.Error())
+	}
+	return c.Do(ctx, endpoint, req)
+}
+
+// PostForm posts the form to the endpoint.
+func (c *Client) PostForm(ctx context.Context, endpoint, target string, data url.Values) (*Response, error) {
+	req, err := http.NewRequest(http.MethodPost, target, strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, cadence.NewCustomError(ErrRejected, err.Error())
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return c.Do(ctx, endpoint, req)
+}
+
+// Do sends req to the endpoint within ctx and the timeout of the endpoint and reads the response. Responses other
+// than 2xx and 3xx are returned as errors. Requests that end because ctx is done do not count against the circuit.
+func (c *Client) Do(ctx context.Context, endpoint string, req *http.Request) (*Response, error) {
+	config := c.config.Endpoint(endpoint)
+	b := c.breaker(endpoint)
+	if !b.allow(config, c.now()) {
+		return nil, unavailable(endpoint, "circuit open")
+	}
+
+	reqCtx, cancel := context.WithTimeout(ctx, config.Timeout)
+	defer cancel()
+	resp, err := c.client.Do(req.WithContext(reqCtx))
+	var body []byte
+	if err == nil {
+		body, err = ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			b.release()
+			return nil, ctx.Err()
+		}
+		b.record(config, false, c.now())
+		return nil, unavailable(endpoint, err.Error())
+	}
+
+	switch code := resp.StatusCode; {
+	case code >= 500 || code == http.StatusTooManyRequests || code == http.StatusRequestTimeout:
+		b.record(config, false, c.now())
+		return nil, unavailable(endpoint, resp.Status)
+	case code >= 400:
+		// the endpoint is up, the request is wrong
+		b.record(config, true, c.now())
+		return nil, cadence.NewCustomError(ErrRejected, fmt.Sprintf("%s: %s", endpoint, resp.Status))
+	}
+	b.record(config, true, c.now())
+	return &Response{StatusCode: resp.StatusCode, Header: resp.Header, Body: body}, nil
+}
+
+func unavailable(endpoint, cause string) error {
+	return cadence.NewCustomError(ErrUnavailable, fmt.Sprintf("%s: %s", endpoint, cause))
+}
+
+func (c *Client) breaker(endpoint string) *breaker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, ok := c.breakers[endpoint]
+	if !ok {
+		b = &breaker{}
+		c.breakers[endpoint] = b
+	}
+	return b
+}
+
+// Message returns the reason of a custom error with the message in its details, err.Error() for other errors.
+func Message(err error) string {
+	customErr, ok := err.(*cadence.CustomError)
+	if !ok || !customErr.HasDetails() {
+		return err.Error()
+	}
+	var message string
+	if customErr.Details(&message) != nil {
+		return customErr.Reason()
+	}
+	return customErr.Reason() + ": " + message
+}
@@ -0,0 +1,127 @@
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/cadence"
+)
+
+func reason(err error) string {
+	if customErr, ok := err.(*cadence.CustomError); ok {
+		return customErr.Reason()
+	}
+	return ""
+}
+
+func TestStatusCodes(t *testing.T) {
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte("SUCCEED"))
+	}))
+	defer server.Close()
+	c := New(Config{EndpointConfig: EndpointConfig{Timeout: time.Second}}, nil)
+
+	resp, err := c.Get(context.Background(), "server", server.URL)
+	require.NoError(t, err)
+	require.Equal(t, "SUCCEED", string(resp.Body))
+
+	for code, expected := range map[int]string{
+		http.StatusNotFound:            ErrRejected,
+		http.StatusBadRequest:          ErrRejected,
+		http.StatusTooManyRequests:     ErrUnavailable,
+		http.StatusServiceUnavailable:  ErrUnavailable,
+		http.StatusInternalServerError: ErrUnavailable,
+	} {
+		status = code
+		_, err := c.Get(context.Background(), "server", server.URL)
+		require.Equal(t, expected, reason(err), "status %d", code)
+	}
+
+	_, err = c.Get(context.Background(), "server", "http://127.0.0.1:1")
+	require.Equal(t, ErrUnavailable, reason(err))
+	require.Contains(t, Message(err), "server: ")
+}
+
+func TestTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	c := New(Config{
+		EndpointConfig: EndpointConfig{Timeout: time.Minute},
+		Endpoints:      map[string]EndpointConfig{"sports": {Timeout: 50 * time.Millisecond}},
+	}, nil)
+	_, err := c.Get(context.Background(), "sports", server.URL)
+	require.Equal(t, ErrUnavailable, reason(err))
+
+	// a cancelled caller gets its own error back
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = c.Get(ctx, "casino", server.URL)
+	require.Equal(t, context.Canceled, err)
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	status := http.StatusServiceUnavailable
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	now := time.Now()
+	c := New(Config{EndpointConfig: EndpointConfig{Timeout: time.Second, Failures: 2, Cooldown: time.Minute}}, nil)
+	c.now = func() time.Time { return now }
+	get := func(endpoint string) error {
+		_, err := c.Get(context.Background(), endpoint, server.URL)
+		return err
+	}
+
+	require.Error(t, get("sports"))
+	require.Error(t, get("sports"))
+	require.Equal(t, 2, calls)
+
+	// open: fails without calling the endpoint, other endpoints are not affected
+	err := get("sports")
+	require.Equal(t, ErrUnavailable, reason(err))
+	require.Contains(t, Message(err), "circuit open")
+	require.Equal(t, 2, calls)
+	require.Error(t, get("casino"))
+	require.Equal(t, 3, calls)
+
+	// a failed trial opens it again
+	now = now.Add(time.Minute)
+	require.Error(t, get("sports"))
+	require.Equal(t, 4, calls)
+	require.Error(t, get("sports"))
+	require.Equal(t, 4, calls)
+
+	// a successful trial closes it
+	now = now.Add(time.Minute)
+	status = http.StatusOK
+	require.NoError(t, get("sports"))
+	require.NoError(t, get("sports"))
+	require.Equal(t, 6, calls)
+}
+
+func TestConfig(t *testing.T) {
+	c := DefaultConfig()
+	require.NoError(t, c.Validate())
+
+	c.Endpoints = map[string]EndpointConfig{"sports": {Timeout: time.Second}, "casino": {Failures: -1}}
+	require.Equal(t, EndpointConfig{Timeout: time.Second, Failures: 5, Cooldown: 30 * time.Second}, c.Endpoint("sports"))
+	require.Equal(t, c.EndpointConfig, c.Endpoint("server"))
+	require.EqualError(t, c.Validate(), "endpoints.casino: failures must not be negative")
+
+	require.Error(t, Config{}.Validate())
+}
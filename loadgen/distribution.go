@@ -0,0 +1,70 @@
+package loadgen
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// Distribution draws the amounts of the generated withdrawals.
+type Distribution interface {
+	Amount(r *rand.Rand) float64
+	String() string
+}
+
+type (
+	fixed   float64
+	uniform struct {
+		min, max float64
+	}
+	// lognormal has the long tail of real withdrawals: most are around the median, a few are much larger.
+	lognormal struct {
+		median, sigma float64
+	}
+)
+
+func (d fixed) Amount(*rand.Rand) float64 { return float64(d) }
+
+func (d fixed) String() string { return fmt.Sprintf("fixed:%g", float64(d)) }
+
+func (d uniform) Amount(r *rand.Rand) float64 { return d.min + r.Float64()*(d.max-d.min) }
+
+func (d uniform) String() string { return fmt.Sprintf("uniform:%g:%g", d.min, d.max) }
+
+func (d lognormal) Amount(r *rand.Rand) float64 { return d.median * math.Exp(d.sigma*r.NormFloat64()) }
+
+func (d lognormal) String() string { return fmt.Sprintf("lognormal:%g:%g", d.median, d.sigma) }
+
+// ParseDistribution parses fixed:<amount>, uniform:<min>:<max> or lognormal:<median>:<sigma>.
+func ParseDistribution(s string) (Distribution, error) {
+	parts := strings.Split(s, ":")
+	params := make([]float64, 0, len(parts)-1)
+	for _, p := range parts[1:] {
+		v, err := strconv.ParseFloat(p, 64)
+		if err != nil || v < 0 {
+			return nil, fmt.Errorf("amount distribution %q: %q is not a non-negative number", s, p)
+		}
+		params = append(params, v)
+	}
+
+	switch {
+	case parts[0] == "fixed" && len(params) == 1 && params[0] > 0:
+		return fixed(params[0]), nil
+	case parts[0] == "uniform" && len(params) == 2 && params[0] > 0 && params[0] <= params[1]:
+		return uniform{min: params[0], max: params[1]}, nil
+	case parts[0] == "lognormal" && len(params) == 2 && params[0] > 0:
+		return lognormal{median: params[0], sigma: params[1]}, nil
+	}
+	return nil, fmt.Errorf("amount distribution %q: use fixed:<amount>, uniform:<min>:<max> or "+
+		"lognormal:<median>:<sigma> with positive amounts", s)
+}
+
+// formatAmount formats an amount the way the server takes it, at least a cent.
+func formatAmount(v float64) string {
+	if v < 0.01 {
+		v = 0.01
+	}
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
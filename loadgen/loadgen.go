@@ -0,0 +1,246 @@
+// Package loadgen starts withdrawals at a steady rate for capacity testing. A simulated reviewer decides a share of
+// them after a think time, the others are left to the automated approvers. Every withdrawal is followed on the
+// withdrawal server until it is completed or rejected, and the run ends with a report of the throughput, the
+// decision latencies and the failures.
+package loadgen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	apiclient "github.com/bartke/cadence-withdrawal-approval/api/client"
+	"github.com/bartke/cadence-withdrawal-approval/withdrawal"
+	"github.com/pborman/uuid"
+	"go.uber.org/zap"
+)
+
+// MaxRate is the highest rate in withdrawals per second, which keeps the interval between starts well above the
+// nanosecond it is counted in.
+const MaxRate = 10000
+
+// Config is a load run.
+type Config struct {
+	// Rate is the number of withdrawals started per second for Duration.
+	Rate     float64
+	Duration time.Duration
+	Amounts  Distribution
+	// Approve and Reject are the shares of withdrawals the reviewer approves and rejects, the rest it leaves alone.
+	Approve float64
+	Reject  float64
+	// Think is the mean time the reviewer takes for a decision, the actual times are exponentially distributed.
+	Think time.Duration
+	// Timeout is how long a withdrawal is followed after its start, Poll how often its state is read.
+	Timeout time.Duration
+	Poll    time.Duration
+	// Seed makes the amounts and the decisions of runs repeatable.
+	Seed int64
+}
+
+// Validate checks the config.
+func (c Config) Validate() error {
+	switch {
+	case c.Rate <= 0 || c.Rate > MaxRate:
+		return fmt.Errorf("rate must be positive and at most %d per second", MaxRate)
+	case c.Duration <= 0:
+		return errors.New("duration must be positive")
+	case c.Amounts == nil:
+		return errors.New("amounts are required")
+	case c.Approve < 0 || c.Reject < 0 || c.Approve+c.Reject > 1:
+		return errors.New("approve and reject rates must be between 0 and 1 and add up to at most 1")
+	case c.Think < 0:
+		return errors.New("think time must not be negative")
+	case c.Timeout <= 0 || c.Poll <= 0:
+		return errors.New("timeout and poll must be positive")
+	}
+	return nil
+}
+
+// Starter creates a withdrawal and starts its workflow.
+type Starter func(ctx context.Context, id string, d apiclient.Details) error
+
+// plan is what happens to a single withdrawal, drawn up front so the random source is only used by the generator.
+type plan struct {
+	id       string
+	details  apiclient.Details
+	decision string
+	think    time.Duration
+}
+
+// Run starts withdrawals with start until the duration is over or ctx is done, follows them on server and returns
+// the report. Withdrawals still followed when ctx is done are reported in the state they were last read in.
+func Run(ctx context.Context, c Config, server *apiclient.Client, start Starter, logger *zap.Logger) (Report, error) {
+	if err := c.Validate(); err != nil {
+		return Report{}, err
+	}
+	r := &run{config: c, server: server, start: start, logger: logger}
+	r.report = Report{Rate: c.Rate, Duration: c.Duration, Amounts: c.Amounts.String()}
+
+	random := rand.New(rand.NewSource(c.Seed))
+	began := time.Now()
+	interval := time.Duration(float64(time.Second) / c.Rate)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	end := time.After(c.Duration)
+
+	var wg sync.WaitGroup
+	planned := 0
+generate:
+	for {
+		id := uuid.New()
+		p := plan{
+			id:      id,
+			details: apiclient.Details{Customer: "load-" + id[:8], Amount: formatAmount(c.Amounts.Amount(random))},
+		}
+		if draw := random.Float64(); draw < c.Approve {
+			p.decision = "APPROVE"
+		} else if draw < c.Approve+c.Reject {
+			p.decision = "REJECT"
+		}
+		if p.decision != "" {
+			p.think = time.Duration(random.ExpFloat64() * float64(c.Think))
+		}
+
+		wg.Add(1)
+		planned++
+		go func() {
+			defer wg.Done()
+			r.follow(ctx, p)
+		}()
+
+		select {
+		case <-ticker.C:
+		case <-end:
+			break generate
+		case <-ctx.Done():
+			break generate
+		}
+	}
+	generated := time.Since(began)
+	logger.Info("Load generated, waiting for the withdrawals.", zap.Int("Withdrawals", planned),
+		zap.Duration("Elapsed", generated))
+	wg.Wait()
+
+	r.report.Elapsed = time.Since(began)
+	r.report.StartRate = float64(r.report.Started) / generated.Seconds()
+	closed := r.report.Completed + r.report.Rejected
+	r.report.Throughput = float64(closed) / r.report.Elapsed.Seconds()
+	r.report.DecisionLatency = percentiles(r.decisionLatencies)
+	r.report.CloseLatency = percentiles(r.closeLatencies)
+	return r.report, nil
+}
+
+type run struct {
+	config Config
+	server *apiclient.Client
+	start  Starter
+	logger *zap.Logger
+
+	mu                sync.Mutex
+	report            Report
+	decisionLatencies []time.Duration
+	closeLatencies    []time.Duration
+}
+
+func (r *run) count(f func(report *Report)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f(&r.report)
+}
+
+// follow starts a withdrawal, lets the reviewer decide it and polls it until it reaches a final state or times out.
+func (r *run) follow(ctx context.Context, p plan) {
+	logger := r.logger.With(zap.String("WithdrawalID", p.id))
+	began := time.Now()
+	if err := r.start(ctx, p.id, p.details); err != nil {
+		logger.Warn("Failed to start withdrawal.", zap.Error(err))
+		r.count(func(report *Report) { report.StartFailures++ })
+		return
+	}
+	r.count(func(report *Report) { report.Started++ })
+
+	ctx, cancel := context.WithTimeout(ctx, r.config.Timeout)
+	defer cancel()
+	if p.decision != "" && sleep(ctx, p.think) {
+		r.review(ctx, logger, p)
+	}
+
+	ticker := time.NewTicker(r.config.Poll)
+	defer ticker.Stop()
+	var record withdrawal.Record
+	read := false
+	for {
+		current, err := r.server.Withdrawal(ctx, p.id)
+		if err != nil && ctx.Err() == nil {
+			logger.Debug("Failed to read withdrawal.", zap.Error(err))
+			r.count(func(report *Report) { report.PollErrors++ })
+		} else if err == nil {
+			record, read = current, true
+		}
+		if read && (record.State == withdrawal.Completed.String() || record.State == withdrawal.Rejected.String()) {
+			break
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			r.count(func(report *Report) {
+				switch {
+				case !read:
+					report.Lost++
+				case record.State == withdrawal.Approved.String():
+					report.Unpaid++
+				default:
+					report.Undecided++
+				}
+			})
+			return
+		}
+	}
+
+	closed := time.Since(began)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if record.State == withdrawal.Completed.String() {
+		r.report.Completed++
+	} else {
+		r.report.Rejected++
+	}
+	if record.DecidedAt != nil {
+		r.decisionLatencies = append(r.decisionLatencies, record.DecidedAt.Sub(record.CreatedAt))
+	}
+	r.closeLatencies = append(r.closeLatencies, closed)
+}
+
+// review decides the withdrawal as manual reviewer unless the approvers were faster.
+func (r *run) review(ctx context.Context, logger *zap.Logger, p plan) {
+	state, err := r.server.Status(ctx, p.id)
+	if err == nil && state != withdrawal.Pending {
+		r.count(func(report *Report) { report.LateReviews++ })
+		return
+	}
+	if err == nil {
+		decision := apiclient.Decision{Action: p.decision, Domain: "manual", Reviewer: "loadgen", Reason: "load test"}
+		err = r.server.Decide(ctx, p.id, decision)
+	}
+	if err != nil {
+		logger.Warn("Failed to review withdrawal.", zap.Error(err))
+		r.count(func(report *Report) { report.ReviewErrors++ })
+		return
+	}
+	r.count(func(report *Report) { report.Reviews++ })
+}
+
+// sleep waits for d and reports whether ctx is still alive.
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
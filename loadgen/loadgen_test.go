@@ -0,0 +1,165 @@
+package loadgen
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	apiclient "github.com/bartke/cadence-withdrawal-approval/api/client"
+	"github.com/bartke/cadence-withdrawal-approval/httpclient"
+	"github.com/bartke/cadence-withdrawal-approval/withdrawal"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fakeServer keeps withdrawals pending until the manual reviewer decides them, approved ones are paid right away.
+func fakeServer() *httptest.Server {
+	var mu sync.Mutex
+	records := map[string]*withdrawal.Record{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		id := r.URL.Query().Get("id")
+		record, ok := records[id]
+		if !ok && r.URL.Path != "/create" {
+			io.WriteString(w, "ERROR:INVALID_ID")
+			return
+		}
+		switch r.URL.Path {
+		case "/create":
+			records[id] = &withdrawal.Record{ID: id, State: "PENDING", CreatedAt: time.Now(),
+				Amount: r.URL.Query().Get("amount")}
+			io.WriteString(w, "SUCCEED")
+		case "/status":
+			io.WriteString(w, record.State)
+		case "/withdrawal":
+			json.NewEncoder(w).Encode(record)
+		case "/action":
+			if r.URL.Query().Get("domain") != "manual" {
+				io.WriteString(w, "ERROR:INVALID_DOMAIN")
+				return
+			}
+			now := time.Now()
+			record.DecidedAt = &now
+			record.State = "COMPLETED"
+			if r.URL.Query().Get("type") == "reject" {
+				record.State = "REJECTED"
+			}
+			io.WriteString(w, "SUCCEED")
+		}
+	}))
+}
+
+func TestRun(t *testing.T) {
+	server := fakeServer()
+	defer server.Close()
+	c := apiclient.New(server.URL, httpclient.New(httpclient.DefaultConfig(), nil))
+
+	var mu sync.Mutex
+	attempts := 0
+	var customers []string
+	start := func(ctx context.Context, id string, d apiclient.Details) error {
+		mu.Lock()
+		attempts++
+		fail := attempts%10 == 0
+		customers = append(customers, d.Customer)
+		mu.Unlock()
+		if fail {
+			return errors.New("cadence unavailable")
+		}
+		return c.Create(ctx, id, d)
+	}
+
+	config := Config{
+		Rate:     200,
+		Duration: 200 * time.Millisecond,
+		Amounts:  uniform{min: 10, max: 20},
+		Approve:  0.5,
+		Reject:   0.3,
+		Think:    time.Millisecond,
+		Timeout:  300 * time.Millisecond,
+		Poll:     5 * time.Millisecond,
+		Seed:     1,
+	}
+	report, err := Run(context.Background(), config, c, start, zap.NewNop())
+	require.NoError(t, err)
+	// require must not fail the test from the goroutines of the starter
+	for _, customer := range customers {
+		require.True(t, strings.HasPrefix(customer, "load-"), customer)
+	}
+
+	require.Equal(t, attempts, report.Started+report.StartFailures)
+	require.Equal(t, attempts/10, report.StartFailures)
+	require.Equal(t, report.Started, report.Completed+report.Rejected+report.Unpaid+report.Undecided+report.Lost)
+	require.Equal(t, report.Reviews, report.Completed+report.Rejected)
+	require.True(t, report.Completed > report.Rejected, "%+v", report)
+	require.True(t, report.Undecided > 0, "%+v", report)
+	require.Zero(t, report.Lost+report.Unpaid+report.ReviewErrors)
+	require.Equal(t, report.Reviews, report.DecisionLatency.Count)
+	require.Equal(t, report.Reviews, report.CloseLatency.Count)
+	require.True(t, report.Throughput > 0)
+	require.Equal(t, report.StartFailures+report.Undecided, report.Failures())
+
+	var out strings.Builder
+	require.NoError(t, report.Write(&out))
+	require.Contains(t, out.String(), "amounts uniform:10:20")
+
+	config.Approve = 0.9
+	require.Error(t, config.Validate())
+	config.Approve = 0.5
+	config.Rate = 2e9
+	require.EqualError(t, config.Validate(), "rate must be positive and at most 10000 per second")
+	config.Rate = MaxRate
+	require.NoError(t, config.Validate())
+}
+
+func TestParseDistribution(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	for s, check := range map[string]func(v float64) bool{
+		"fixed:25":          func(v float64) bool { return v == 25 },
+		"uniform:10:20":     func(v float64) bool { return v >= 10 && v < 20 },
+		"lognormal:100:0.5": func(v float64) bool { return v > 0 },
+		"lognormal:100:0":   func(v float64) bool { return v == 100 },
+		"uniform:0.01:0.01": func(v float64) bool { return v == 0.01 },
+	} {
+		d, err := ParseDistribution(s)
+		require.NoError(t, err, s)
+		require.Equal(t, s, d.String())
+		for i := 0; i < 100; i++ {
+			v := d.Amount(random)
+			require.True(t, check(v), "%s drew %g", s, v)
+		}
+	}
+
+	for _, s := range []string{"", "fixed", "fixed:0", "fixed:-1", "uniform:20:10", "lognormal:x:1", "normal:1:1"} {
+		_, err := ParseDistribution(s)
+		require.Error(t, err, s)
+	}
+	require.Equal(t, "0.01", formatAmount(0.001))
+	require.Equal(t, "12.35", formatAmount(12.345))
+}
+
+func TestPercentiles(t *testing.T) {
+	require.Equal(t, Percentiles{}, percentiles(nil))
+
+	var samples []time.Duration
+	for i := 100; i > 0; i-- {
+		samples = append(samples, time.Duration(i)*time.Millisecond)
+	}
+	require.Equal(t, Percentiles{
+		Count: 100,
+		P50:   50 * time.Millisecond,
+		P90:   90 * time.Millisecond,
+		P99:   99 * time.Millisecond,
+		Max:   100 * time.Millisecond,
+	}, percentiles(samples))
+	require.Equal(t, time.Second, percentiles([]time.Duration{time.Second}).P99)
+}
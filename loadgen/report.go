@@ -0,0 +1,102 @@
+package loadgen
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// Report is the outcome of a load run. Every started withdrawal ends up in exactly one of Completed, Rejected,
+// Unpaid, Undecided and Lost.
+type Report struct {
+	Rate     float64       `json:"rate"`
+	Duration time.Duration `json:"duration"`
+	Amounts  string        `json:"amounts"`
+
+	Started       int `json:"started"`
+	StartFailures int `json:"start_failures"`
+	// Completed withdrawals were approved and paid out.
+	Completed int `json:"completed"`
+	Rejected  int `json:"rejected"`
+	// Unpaid withdrawals were approved but not paid out before the timeout.
+	Unpaid int `json:"unpaid"`
+	// Undecided withdrawals were still pending at the timeout.
+	Undecided int `json:"undecided"`
+	// Lost withdrawals could not be read from the server.
+	Lost int `json:"lost"`
+
+	// Reviews counts the decisions of the simulated reviewer, LateReviews those that found the withdrawal decided.
+	Reviews      int `json:"reviews"`
+	LateReviews  int `json:"late_reviews"`
+	ReviewErrors int `json:"review_errors"`
+	// PollErrors counts failed reads of a withdrawal, its next poll retries.
+	PollErrors int `json:"poll_errors"`
+
+	Elapsed time.Duration `json:"elapsed"`
+	// StartRate is the achieved rate of started withdrawals per second.
+	StartRate float64 `json:"start_rate"`
+	// Throughput is the rate of withdrawals per second that reached a final state.
+	Throughput float64 `json:"throughput"`
+	// DecisionLatency is from creation to decision as recorded by the server, CloseLatency from the start until the
+	// generator saw the withdrawal completed or rejected.
+	DecisionLatency Percentiles `json:"decision_latency"`
+	CloseLatency    Percentiles `json:"close_latency"`
+}
+
+// Failures is the number of withdrawals that did not reach a final state.
+func (r Report) Failures() int {
+	return r.StartFailures + r.Unpaid + r.Undecided + r.Lost
+}
+
+// Percentiles summarize latencies, all zero without samples.
+type Percentiles struct {
+	Count int           `json:"count"`
+	P50   time.Duration `json:"p50"`
+	P90   time.Duration `json:"p90"`
+	P99   time.Duration `json:"p99"`
+	Max   time.Duration `json:"max"`
+}
+
+// percentiles computes nearest-rank percentiles. It sorts samples.
+func percentiles(samples []time.Duration) Percentiles {
+	if len(samples) == 0 {
+		return Percentiles{}
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	rank := func(p int) time.Duration {
+		i := (p*len(samples)+99)/100 - 1
+		return samples[i]
+	}
+	return Percentiles{Count: len(samples), P50: rank(50), P90: rank(90), P99: rank(99), Max: samples[len(samples)-1]}
+}
+
+// Write writes the report as a table.
+func (r Report) Write(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "load\t%g/s for %s, amounts %s\n", r.Rate, r.Duration, r.Amounts)
+	fmt.Fprintf(tw, "started\t%d (%.2f/s), %d failed to start\n", r.Started, r.StartRate, r.StartFailures)
+	fmt.Fprintf(tw, "completed\t%d\n", r.Completed)
+	fmt.Fprintf(tw, "rejected\t%d\n", r.Rejected)
+	fmt.Fprintf(tw, "approved, unpaid\t%d\n", r.Unpaid)
+	fmt.Fprintf(tw, "undecided\t%d\n", r.Undecided)
+	fmt.Fprintf(tw, "lost\t%d\n", r.Lost)
+	fmt.Fprintf(tw, "reviews\t%d, %d late, %d failed\n", r.Reviews, r.LateReviews, r.ReviewErrors)
+	fmt.Fprintf(tw, "poll errors\t%d\n", r.PollErrors)
+	fmt.Fprintf(tw, "throughput\t%.2f/s over %s\n", r.Throughput, r.Elapsed.Round(time.Millisecond))
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "latency\tcount\tp50\tp90\tp99\tmax")
+	for _, l := range []struct {
+		name string
+		p    Percentiles
+	}{{"decision", r.DecisionLatency}, {"close", r.CloseLatency}} {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\t%s\n", l.name, l.p.Count, round(l.p.P50), round(l.p.P90),
+			round(l.p.P99), round(l.p.Max))
+	}
+	return tw.Flush()
+}
+
+func round(d time.Duration) time.Duration {
+	return d.Round(time.Millisecond)
+}
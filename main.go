@@ -1,63 +0,0 @@
-package main
-
-import (
-	"flag"
-	"time"
-
-	"github.com/bartke/cadence-withdrawal-approval/common"
-	"github.com/pborman/uuid"
-	"go.uber.org/cadence/client"
-	"go.uber.org/cadence/worker"
-)
-
-const (
-	// ApplicationName is the task list for this sample
-	ApplicationName = "withdrawalGroup"
-)
-
-var (
-	withdrawalServerHostPort = "http://localhost:8099"
-	autoApprovalSystemSports = "http://localhost:8091"
-	autoApprovalSystemCasino = "http://localhost:8092"
-)
-
-// This needs to be done as part of a bootstrap step when the process starts.
-// The workers are supposed to be long running.
-func startWorkers(h *common.SampleHelper) {
-	// Configure worker options.
-	workerOptions := worker.Options{
-		MetricsScope: h.Scope,
-		Logger:       h.Logger,
-	}
-	h.StartWorkers(h.Config.DomainName, ApplicationName, workerOptions)
-}
-
-func startWorkflow(h *common.SampleHelper, withdrawalID string) {
-	workflowOptions := client.StartWorkflowOptions{
-		ID:                              "withdrawal_" + uuid.New(),
-		TaskList:                        ApplicationName,
-		ExecutionStartToCloseTimeout:    time.Minute,
-		DecisionTaskStartToCloseTimeout: time.Minute,
-	}
-	h.StartWorkflow(workflowOptions, SampleWithdrawalWorkflow, withdrawalID)
-}
-
-func main() {
-	var mode string
-	flag.StringVar(&mode, "m", "trigger", "Mode is worker or trigger.")
-	flag.Parse()
-
-	var h common.SampleHelper
-	h.SetupServiceConfig()
-
-	switch mode {
-	case "worker":
-		startWorkers(&h)
-
-		// The workers are supposed to be long running process that should not exit.
-		// Use select{} to block indefinitely for samples, you can quit by CMD+C.
-		select {}
-	case "trigger":
-		startWorkflow(&h, uuid.New())
-	}
-}
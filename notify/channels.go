@@ -0,0 +1,86 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// SMTPChannel sends plain text mail, e.g. to a local catcher like MailHog.
+type SMTPChannel struct {
+	Addr string
+	From string
+}
+
+func (c *SMTPChannel) Name() string { return Email }
+
+func (c *SMTPChannel) Send(ctx context.Context, msg Message) error {
+	if msg.Recipient.Email == "" {
+		return nil
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", c.From)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.Recipient.Email)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	b.WriteString(msg.Body + "\r\n")
+	return smtp.SendMail(c.Addr, nil, c.From, []string{msg.Recipient.Email}, []byte(b.String()))
+}
+
+// SMSChannel is a stub that logs instead of calling an SMS gateway.
+type SMSChannel struct {
+	Logger *zap.Logger
+}
+
+func (c *SMSChannel) Name() string { return SMS }
+
+func (c *SMSChannel) Send(ctx context.Context, msg Message) error {
+	if msg.Recipient.Phone == "" {
+		return nil
+	}
+	c.Logger.Info("SMS sent.", zap.String("To", msg.Recipient.Phone), zap.String("Text", msg.Subject))
+	return nil
+}
+
+// InAppChannel stores the message with the withdrawal server, where the
+// customer's app picks it up.
+type InAppChannel struct {
+	URL string
+}
+
+func (c *InAppChannel) Name() string { return InApp }
+
+func (c *InAppChannel) Send(ctx context.Context, msg Message) error {
+	formData := url.Values{}
+	formData.Add("id", msg.WithdrawalID)
+	formData.Add("customer", msg.Recipient.ID)
+	formData.Add("subject", msg.Subject)
+	formData.Add("body", msg.Body)
+
+	req, err := http.NewRequest(http.MethodPost, c.URL, strings.NewReader(formData.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	if string(body) != "SUCCEED" {
+		return errors.New(string(body))
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// Outcomes customers are notified about.
+const (
+	Approved  = "approved"
+	Rejected  = "rejected"
+	Completed = "completed"
+)
+
+// Channel names accepted in the configuration.
+const (
+	Email = "email"
+	SMS   = "sms"
+	InApp = "inapp"
+)
+
+// Config selects and configures the notification channels.
+type Config struct {
+	Channels   []string `yaml:"channels"`
+	SMTPAddr   string   `yaml:"smtp"`
+	From       string   `yaml:"from"`
+	MailDomain string   `yaml:"maildomain"`
+	Locale     string   `yaml:"locale"`
+}
+
+// Recipient is the customer a message is addressed to.
+type Recipient struct {
+	ID     string
+	Email  string
+	Phone  string
+	Locale string
+}
+
+// Message is a rendered notification.
+type Message struct {
+	WithdrawalID string
+	Outcome      string
+	Recipient    Recipient
+	Subject      string
+	Body         string
+}
+
+// Channel delivers messages over a single medium.
+type Channel interface {
+	Name() string
+	Send(ctx context.Context, msg Message) error
+}
+
+// Notifier renders templates and fans messages out to its channels.
+type Notifier struct {
+	channels []Channel
+}
+
+// New builds a notifier with the channels named in cfg. inAppURL is the
+// message store endpoint of the withdrawal server.
+func New(cfg Config, inAppURL string, logger *zap.Logger) (*Notifier, error) {
+	n := &Notifier{}
+	for _, name := range cfg.Channels {
+		switch strings.ToLower(name) {
+		case Email:
+			n.channels = append(n.channels, &SMTPChannel{Addr: cfg.SMTPAddr, From: cfg.From})
+		case SMS:
+			n.channels = append(n.channels, &SMSChannel{Logger: logger})
+		case InApp:
+			n.channels = append(n.channels, &InAppChannel{URL: inAppURL})
+		default:
+			return nil, fmt.Errorf("unknown notification channel %q", name)
+		}
+	}
+	return n, nil
+}
+
+// Enabled reports whether any channel is configured.
+func (n *Notifier) Enabled() bool {
+	return len(n.channels) > 0
+}
+
+// Notify renders the message for outcome in the recipient's locale and sends
+// it on every channel. All channels are attempted; failures are returned
+// together.
+func (n *Notifier) Notify(ctx context.Context, withdrawalID, outcome string, to Recipient) error {
+	msg, err := Render(withdrawalID, outcome, to)
+	if err != nil {
+		return err
+	}
+
+	var failed []string
+	for _, c := range n.channels {
+		if err := c.Send(ctx, msg); err != nil {
+			failed = append(failed, c.Name()+": "+err.Error())
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("notification failed on %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type recordingChannel struct {
+	name string
+	err  error
+	sent []Message
+}
+
+func (c *recordingChannel) Name() string { return c.name }
+
+func (c *recordingChannel) Send(ctx context.Context, msg Message) error {
+	c.sent = append(c.sent, msg)
+	return c.err
+}
+
+func TestRenderLocaleFallback(t *testing.T) {
+	msg, err := Render("w1", Rejected, Recipient{Locale: "de"})
+	require.NoError(t, err)
+	require.Equal(t, "Ihre Auszahlung w1 wurde abgelehnt", msg.Subject)
+
+	msg, err = Render("w1", Completed, Recipient{Locale: "xx"})
+	require.NoError(t, err)
+	require.Equal(t, "Your withdrawal w1 has been paid out", msg.Subject)
+
+	_, err = Render("w1", "unknown", Recipient{})
+	require.Error(t, err)
+}
+
+func TestNotifyAttemptsAllChannels(t *testing.T) {
+	broken := &recordingChannel{name: "broken", err: errors.New("down")}
+	working := &recordingChannel{name: "working"}
+	n := &Notifier{channels: []Channel{broken, working}}
+
+	err := n.Notify(context.Background(), "w1", Approved, Recipient{ID: "c1"})
+	require.EqualError(t, err, "notification failed on broken: down")
+	require.Len(t, working.sent, 1)
+	require.Equal(t, "c1", working.sent[0].Recipient.ID)
+}
+
+func TestNewRejectsUnknownChannel(t *testing.T) {
+	_, err := New(Config{Channels: []string{"pigeon"}}, "", nil)
+	require.Error(t, err)
+}
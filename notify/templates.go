@@ -0,0 +1,72 @@
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+)
+
+// DefaultLocale is used when no template exists for the recipient's locale.
+const DefaultLocale = "en"
+
+type messageTemplate struct {
+	subject *template.Template
+	body    *template.Template
+}
+
+func newTemplate(subject, body string) messageTemplate {
+	return messageTemplate{
+		subject: template.Must(template.New("subject").Parse(subject)),
+		body:    template.Must(template.New("body").Parse(body)),
+	}
+}
+
+// templates are keyed by locale, then outcome.
+var templates = map[string]map[string]messageTemplate{
+	"en": {
+		Approved: newTemplate(
+			"Your withdrawal {{.WithdrawalID}} was approved",
+			"Good news, your withdrawal {{.WithdrawalID}} has been approved and the payout is on its way."),
+		Rejected: newTemplate(
+			"Your withdrawal {{.WithdrawalID}} was not approved",
+			"We could not approve your withdrawal {{.WithdrawalID}}. Please contact support if you have any questions."),
+		Completed: newTemplate(
+			"Your withdrawal {{.WithdrawalID}} has been paid out",
+			"Your withdrawal {{.WithdrawalID}} has been paid out. Depending on your bank it can take up to three business days to arrive."),
+	},
+	"de": {
+		Approved: newTemplate(
+			"Ihre Auszahlung {{.WithdrawalID}} wurde genehmigt",
+			"Ihre Auszahlung {{.WithdrawalID}} wurde genehmigt und ist auf dem Weg zu Ihnen."),
+		Rejected: newTemplate(
+			"Ihre Auszahlung {{.WithdrawalID}} wurde abgelehnt",
+			"Wir konnten Ihre Auszahlung {{.WithdrawalID}} leider nicht genehmigen. Bei Fragen wenden Sie sich bitte an den Support."),
+		Completed: newTemplate(
+			"Ihre Auszahlung {{.WithdrawalID}} wurde ausgezahlt",
+			"Ihre Auszahlung {{.WithdrawalID}} wurde ausgezahlt. Je nach Bank kann die Gutschrift bis zu drei Werktage dauern."),
+	},
+}
+
+// Render builds the message for outcome, falling back to DefaultLocale.
+func Render(withdrawalID, outcome string, to Recipient) (Message, error) {
+	byOutcome, ok := templates[to.Locale]
+	if !ok {
+		byOutcome = templates[DefaultLocale]
+	}
+	t, ok := byOutcome[outcome]
+	if !ok {
+		return Message{}, fmt.Errorf("no template for outcome %q", outcome)
+	}
+
+	msg := Message{WithdrawalID: withdrawalID, Outcome: outcome, Recipient: to}
+	var subject, body bytes.Buffer
+	if err := t.subject.Execute(&subject, msg); err != nil {
+		return Message{}, err
+	}
+	if err := t.body.Execute(&body, msg); err != nil {
+		return Message{}, err
+	}
+	msg.Subject = subject.String()
+	msg.Body = body.String()
+	return msg, nil
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	apiclient "github.com/bartke/cadence-withdrawal-approval/api/client"
+	"github.com/bartke/cadence-withdrawal-approval/common"
+	"github.com/bartke/cadence-withdrawal-approval/withdrawal"
+	"go.uber.org/zap"
+)
+
+// maxBulkItems bounds a single bulk request.
+const maxBulkItems = 100
+
+// bulkHandler applies one manual approve or reject with a shared reason to
+// several withdrawals. Each item takes the same path as a single action, so
+// the waiting workflow is completed per withdrawal. Form values: id (repeated),
+// type, reviewer, reason and is_api_call.
+func (s *Server) bulkHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		fmt.Fprint(w, "ERROR:INVALID_METHOD")
+		return
+	}
+	err := r.ParseForm()
+	if err != nil {
+		fmt.Fprint(w, "ERROR:INVALID_FORM_DATA")
+		return
+	}
+	isAPICall := r.FormValue("is_api_call") == "true"
+	actionType := r.PostFormValue("type")
+	action := withdrawal.ParseAction(actionType)
+	if action != withdrawal.Approve && action != withdrawal.Reject {
+		fmt.Fprint(w, "ERROR:INVALID_ACTION")
+		return
+	}
+	ids := r.PostForm["id"]
+	if len(ids) == 0 {
+		fmt.Fprint(w, "ERROR:NO_ITEMS")
+		return
+	}
+	if len(ids) > maxBulkItems {
+		fmt.Fprint(w, "ERROR:TOO_MANY_ITEMS")
+		return
+	}
+	reviewer := r.PostFormValue("reviewer")
+	reason := r.PostFormValue("reason")
+
+	results := make([]apiclient.BulkResult, 0, len(ids))
+	failed := 0
+	for _, id := range ids {
+		result := s.bulkItem(r.Context(), id, actionType, reviewer, reason)
+		if result != "SUCCEED" {
+			failed++
+		}
+		results = append(results, apiclient.BulkResult{ID: id, Result: result})
+	}
+	common.Logger(r.Context()).Info("Bulk action applied.", zap.String("Action", string(action)),
+		zap.String("Reviewer", reviewer), zap.Int("Succeeded", len(ids)-failed), zap.Int("Total", len(ids)))
+
+	if isAPICall {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(results)
+		return
+	}
+
+	notice := fmt.Sprintf("Bulk %s: %d of %d succeeded.", action, len(ids)-failed, len(ids))
+	for _, res := range results {
+		if res.Result != "SUCCEED" {
+			notice += fmt.Sprintf(" %s: %s.", res.ID, res.Result)
+		}
+	}
+	s.renderList(w, notice)
+}
+
+// bulkItem decides a single withdrawal of a bulk request. Only withdrawals
+// still awaiting a decision are touched, and the four-eyes rules apply to
+// every item as they do to a single action.
+func (s *Server) bulkItem(ctx context.Context, id, actionType, reviewer, reason string) string {
+	wd, ok := withdrawal.DB[id]
+	if !ok {
+		return "ERROR:INVALID_ID"
+	}
+	if wd.State() != withdrawal.Pending {
+		return "ERROR:INVALID_STATE"
+	}
+	logger := common.Logger(ctx).With(zap.String("WithdrawalID", id), zap.String("Approver", string(withdrawal.Manual)))
+	ctx = common.WithLogger(ctx, logger)
+	return s.applyAction(ctx, id, actionType, string(withdrawal.Manual), reviewer, reason)
+}
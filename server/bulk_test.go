@@ -0,0 +1,165 @@
+package server
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	apiclient "github.com/bartke/cadence-withdrawal-approval/api/client"
+	"github.com/bartke/cadence-withdrawal-approval/httpclient"
+	"github.com/bartke/cadence-withdrawal-approval/withdrawal"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+	"go.uber.org/cadence/client"
+)
+
+// fakeCadence records the manual reviews the server completes.
+type fakeCadence struct {
+	client.Client
+	completed map[string]interface{}
+}
+
+func (c *fakeCadence) CompleteActivity(ctx context.Context, taskToken []byte, result interface{}, err error) error {
+	c.completed[string(taskToken)] = result
+	return nil
+}
+
+// newTestServer serves a server with the rules. The withdrawals the test creates are removed from withdrawal.DB
+// on cleanup.
+func newTestServer(t *testing.T, rules withdrawal.Rules) (*apiclient.Client, *fakeCadence, func()) {
+	existing := map[string]bool{}
+	for id := range withdrawal.DB {
+		existing[id] = true
+	}
+	cadence := &fakeCadence{completed: map[string]interface{}{}}
+	s := New(cadence, "withdrawalGroup", tally.NoopScope)
+	s.Rules = rules
+	server := httptest.NewServer(s)
+	c := apiclient.New(server.URL, httpclient.New(httpclient.DefaultConfig(), nil))
+	return c, cadence, func() {
+		server.Close()
+		for id := range withdrawal.DB {
+			if !existing[id] {
+				delete(withdrawal.DB, id)
+			}
+		}
+	}
+}
+
+func TestBulk(t *testing.T) {
+	c, cadence, cleanup := newTestServer(t, withdrawal.Rules{SecondApprovalAbove: 1000})
+	defer cleanup()
+	ctx := context.Background()
+	for id, amount := range map[string]string{"small": "50", "large": "5000", "unreviewed": "5000", "closed": ""} {
+		require.NoError(t, c.Create(ctx, id, apiclient.Details{Customer: "c1", Amount: amount}))
+	}
+	require.NoError(t, c.RegisterCallback(ctx, "small", []byte("token-small")))
+	require.NoError(t, c.Decide(ctx, "closed", apiclient.Decision{Action: "REJECT", Domain: "manual"}))
+
+	approve := apiclient.Decision{Action: "APPROVE", Reviewer: "alice", Reason: "known customer"}
+	results, err := c.Bulk(ctx, []string{"small", "large", "missing", "closed"}, approve)
+	require.NoError(t, err)
+	require.Equal(t, []apiclient.BulkResult{
+		{ID: "small", Result: "SUCCEED"},
+		{ID: "large", Result: "SUCCEED"},
+		{ID: "missing", Result: "ERROR:INVALID_ID"},
+		{ID: "closed", Result: "ERROR:INVALID_STATE"},
+	}, results)
+	require.Equal(t, map[string]interface{}{"token-small": "APPROVED"}, cadence.completed)
+	requireState(t, c, "small", withdrawal.Approved)
+	// the large withdrawal waits for a second reviewer
+	requireState(t, c, "large", withdrawal.Pending)
+
+	results, err = c.Bulk(ctx, []string{"large", "unreviewed"}, approve)
+	require.NoError(t, err)
+	require.Equal(t, []apiclient.BulkResult{
+		{ID: "large", Result: "ERROR:SAME_REVIEWER"},
+		{ID: "unreviewed", Result: "SUCCEED"},
+	}, results)
+	requireState(t, c, "large", withdrawal.Pending)
+
+	approve.Reviewer = "bob"
+	results, err = c.Bulk(ctx, []string{"large"}, approve)
+	require.NoError(t, err)
+	require.Equal(t, []apiclient.BulkResult{{ID: "large", Result: "SUCCEED"}}, results)
+	requireState(t, c, "large", withdrawal.Approved)
+
+	results, err = c.Bulk(ctx, []string{"unreviewed"}, apiclient.Decision{Action: "REJECT", Reviewer: "alice"})
+	require.NoError(t, err)
+	require.Equal(t, []apiclient.BulkResult{{ID: "unreviewed", Result: "SUCCEED"}}, results)
+	requireState(t, c, "unreviewed", withdrawal.Rejected)
+
+	record, err := c.Withdrawal(ctx, "large")
+	require.NoError(t, err)
+	require.Equal(t, "bob", record.ManualReviewer)
+}
+
+func TestBulkRulesApplyToSingleActions(t *testing.T) {
+	c, _, cleanup := newTestServer(t, withdrawal.Rules{SecondApprovalAbove: 1000})
+	defer cleanup()
+	ctx := context.Background()
+	require.NoError(t, c.Create(ctx, "large", apiclient.Details{Amount: "5000"}))
+
+	err := c.Decide(ctx, "large", apiclient.Decision{Action: "APPROVE", Domain: "manual"})
+	require.Equal(t, &apiclient.Error{Code: "REVIEWER_REQUIRED"}, err)
+	require.NoError(t, c.Decide(ctx, "large", apiclient.Decision{Action: "APPROVE", Domain: "manual", Reviewer: "alice"}))
+	err = c.Decide(ctx, "large", apiclient.Decision{Action: "APPROVE", Domain: "manual", Reviewer: "alice"})
+	require.Equal(t, &apiclient.Error{Code: "SAME_REVIEWER"}, err)
+	requireState(t, c, "large", withdrawal.Pending)
+
+	// the automated approvers are not subject to the rules
+	require.NoError(t, c.Decide(ctx, "large", apiclient.Decision{Action: "APPROVE", Domain: "sports"}))
+	require.NoError(t, c.Decide(ctx, "large", apiclient.Decision{Action: "APPROVE", Domain: "casino"}))
+	requireState(t, c, "large", withdrawal.Approved)
+}
+
+func TestBulkInvalidRequests(t *testing.T) {
+	c, _, cleanup := newTestServer(t, withdrawal.Rules{})
+	defer cleanup()
+	ctx := context.Background()
+
+	_, err := c.Bulk(ctx, []string{"a"}, apiclient.Decision{Action: "PAYOUT"})
+	require.Equal(t, &apiclient.Error{Code: "INVALID_ACTION"}, err)
+	_, err = c.Bulk(ctx, nil, apiclient.Decision{Action: "APPROVE"})
+	require.Equal(t, &apiclient.Error{Code: "NO_ITEMS"}, err)
+	ids := make([]string, maxBulkItems+1)
+	for i := range ids {
+		ids[i] = strconv.Itoa(i)
+	}
+	_, err = c.Bulk(ctx, ids, apiclient.Decision{Action: "APPROVE"})
+	require.Equal(t, &apiclient.Error{Code: "TOO_MANY_ITEMS"}, err)
+
+	resp, err := http.Get(c.URL("/bulk"))
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	require.Equal(t, "ERROR:INVALID_METHOD", string(body))
+}
+
+func TestBulkConsole(t *testing.T) {
+	c, _, cleanup := newTestServer(t, withdrawal.Rules{SecondApprovalAbove: 1000})
+	defer cleanup()
+	ctx := context.Background()
+	require.NoError(t, c.Create(ctx, "small", apiclient.Details{Amount: "50"}))
+	require.NoError(t, c.Create(ctx, "large", apiclient.Details{Amount: "5000"}))
+
+	resp, err := http.PostForm(c.URL("/bulk"), url.Values{"id": {"small", "large"}, "type": {"approve"}})
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	require.True(t, strings.Contains(string(body), "Bulk APPROVE: 1 of 2 succeeded. large: ERROR:REVIEWER_REQUIRED."),
+		"%s", body)
+}
+
+func requireState(t *testing.T, c *apiclient.Client, id string, want withdrawal.State) {
+	state, err := c.Status(context.Background(), id)
+	require.NoError(t, err)
+	require.Equal(t, want, state, id)
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bartke/cadence-withdrawal-approval/common"
+	"github.com/bartke/cadence-withdrawal-approval/withdrawal"
+	"go.uber.org/zap"
+)
+
+const dateLayout = "2006-01-02"
+
+// exportHandler returns withdrawals created in a date range as CSV or JSON
+// Lines. Query: format=csv|jsonl, from and to as dates (to is inclusive) or
+// RFC 3339 timestamps.
+func (s *Server) exportHandler(w http.ResponseWriter, r *http.Request) {
+	from, to, err := parseRange(r.URL.Query().Get("from"), r.URL.Query().Get("to"))
+	if err != nil {
+		fmt.Fprint(w, "ERROR:INVALID_RANGE")
+		return
+	}
+	records := withdrawal.Export(from, to)
+
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", "attachment; filename=\"withdrawals.csv\"")
+		err = withdrawal.WriteCSV(w, records)
+	case "jsonl":
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		err = withdrawal.WriteJSONLines(w, records)
+	default:
+		fmt.Fprint(w, "ERROR:INVALID_FORMAT")
+		return
+	}
+	if err != nil {
+		common.Logger(r.Context()).Error("Failed to export withdrawals.", zap.Error(err))
+		return
+	}
+	common.Logger(r.Context()).Info("Exported withdrawals.", zap.Int("Count", len(records)),
+		zap.Time("From", from), zap.Time("To", to))
+}
+
+// payoutsHandler lists the payout provider's records.
+func (s *Server) payoutsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(withdrawal.Ledger)
+}
+
+// parseRange defaults to everything created up to now.
+func parseRange(fromValue, toValue string) (time.Time, time.Time, error) {
+	from := time.Time{}
+	to := time.Now().Add(time.Second)
+	var err error
+	if fromValue != "" {
+		if from, err = parseBound(fromValue, false); err != nil {
+			return from, to, err
+		}
+	}
+	if toValue != "" {
+		if to, err = parseBound(toValue, true); err != nil {
+			return from, to, err
+		}
+	}
+	if !from.Before(to) {
+		return from, to, errors.New("empty range")
+	}
+	return from, to, nil
+}
+
+func parseBound(value string, end bool) (time.Time, error) {
+	if t, err := time.Parse(dateLayout, value); err == nil {
+		if end {
+			// include the whole day
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
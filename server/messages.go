@@ -0,0 +1,49 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bartke/cadence-withdrawal-approval/common"
+	"go.uber.org/zap"
+)
+
+// message is an in-app notification for a customer.
+type message struct {
+	WithdrawalID string    `json:"withdrawal_id"`
+	Customer     string    `json:"customer"`
+	Subject      string    `json:"subject"`
+	Body         string    `json:"body"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// messagesHandler stores a message on POST and lists a customer's messages on GET.
+func (s *Server) messagesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.messages[r.URL.Query().Get("customer")])
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		fmt.Fprint(w, "ERROR:INVALID_FORM_DATA")
+		return
+	}
+	m := message{
+		WithdrawalID: r.PostFormValue("id"),
+		Customer:     r.PostFormValue("customer"),
+		Subject:      r.PostFormValue("subject"),
+		Body:         r.PostFormValue("body"),
+		CreatedAt:    time.Now(),
+	}
+	if m.Customer == "" {
+		fmt.Fprint(w, "ERROR:INVALID_CUSTOMER")
+		return
+	}
+	s.messages[m.Customer] = append(s.messages[m.Customer], m)
+	fmt.Fprint(w, "SUCCEED")
+	common.Logger(r.Context()).Info("Message stored.", zap.String("Customer", m.Customer), zap.String("Subject", m.Subject))
+}
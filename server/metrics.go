@@ -0,0 +1,23 @@
+package server
+
+import (
+	"github.com/bartke/cadence-withdrawal-approval/withdrawal"
+)
+
+// recordAction counts the actions the server received per domain and updates the manual review queue.
+func (s *Server) recordAction(domainName, actionType string) {
+	tags := map[string]string{"domain": domainName, "action": actionType}
+	s.Scope.Tagged(tags).Counter("server_actions").Inc(1)
+	s.updateQueueDepth()
+}
+
+// updateQueueDepth reports how many pending withdrawals wait for a manual review.
+func (s *Server) updateQueueDepth() {
+	depth := 0
+	for _, wd := range withdrawal.DB {
+		if wd.State() == withdrawal.Pending && wd.DomainState(withdrawal.Manual) == withdrawal.Pending {
+			depth++
+		}
+	}
+	s.Scope.Gauge("manual_queue_depth").Update(float64(depth))
+}
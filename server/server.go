@@ -1,48 +1,93 @@
-package main
+// Package server is the withdrawal server: the reviewer console, the API the activities and the CLI call, the in-app
+// messages and the webhook events. cmd/dummy-server serves it, tests serve it on httptest servers.
+package server
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
-	"log"
+	"html"
 	"net/http"
 	"sort"
+	"strconv"
+	"sync"
 
 	"github.com/bartke/cadence-withdrawal-approval/common"
+	"github.com/bartke/cadence-withdrawal-approval/webhook"
 	"github.com/bartke/cadence-withdrawal-approval/withdrawal"
+	"github.com/uber-go/tally"
 	"go.uber.org/cadence/client"
+	"go.uber.org/zap"
 )
 
-/**
- * Supports to list withdrawals, create new withdrawal, update withdrawal state and checking withdrawal state.
- */
+// mu serializes the requests of all servers, the withdrawals are kept in withdrawal.DB and are not safe for
+// concurrent use.
+var mu sync.Mutex
 
-var tokenMap = make(map[string][]byte)
+// Server is the withdrawal server. It supports to list withdrawals, create new withdrawal, update withdrawal state
+// and checking withdrawal state.
+type Server struct {
+	// Cadence completes the manual reviews waiting for a decision and starts the webhook deliveries.
+	Cadence client.Client
+	// TaskList is the task list of the webhook deliveries.
+	TaskList string
+	// Scope gets the metrics of the server.
+	Scope tally.Scope
+	// Rules are the four-eyes rules every manual decision goes through.
+	Rules withdrawal.Rules
 
-var workflowClient client.Client
+	mux    *http.ServeMux
+	tokens map[string][]byte
+	// messages are the in-app messages keyed by customer
+	messages    map[string][]message
+	deadLetters []webhook.DeadLetter
+}
 
-func main() {
-	var h common.SampleHelper
-	h.SetupServiceConfig()
-	var err error
-	workflowClient, err = h.Builder.BuildCadenceClient()
-	if err != nil {
-		panic(err)
+// New returns a server that completes the manual reviews and starts the webhook deliveries on taskList with
+// cadenceClient.
+func New(cadenceClient client.Client, taskList string, scope tally.Scope) *Server {
+	s := &Server{
+		Cadence:  cadenceClient,
+		TaskList: taskList,
+		Scope:    scope,
+		mux:      http.NewServeMux(),
+		tokens:   make(map[string][]byte),
+		messages: make(map[string][]message),
 	}
+	s.mux.HandleFunc("/", s.listHandler)
+	s.mux.HandleFunc("/list", s.listHandler)
+	s.mux.HandleFunc("/create", s.createHandler)
+	s.mux.HandleFunc("/action", s.actionHandler)
+	s.mux.HandleFunc("/bulk", s.bulkHandler)
+	s.mux.HandleFunc("/status", s.statusHandler)
+	s.mux.HandleFunc("/withdrawal", s.withdrawalHandler)
+	s.mux.HandleFunc("/export", s.exportHandler)
+	s.mux.HandleFunc("/payouts", s.payoutsHandler)
+	s.mux.HandleFunc("/registerCallback", s.callbackHandler)
+	s.mux.HandleFunc("/webhooks/deadletter", s.deadLetterHandler)
+	s.mux.HandleFunc("/messages", s.messagesHandler)
+	return s
+}
 
-	http.HandleFunc("/", listHandler)
-	http.HandleFunc("/list", listHandler)
-	http.HandleFunc("/create", createHandler)
-	http.HandleFunc("/action", actionHandler)
-	http.HandleFunc("/status", statusHandler)
-	http.HandleFunc("/registerCallback", callbackHandler)
+// ServeHTTP serves the requests one at a time.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	mu.Lock()
+	defer mu.Unlock()
+	s.mux.ServeHTTP(w, r)
+}
 
-	log.Println("Starting server on :8099...")
-	http.ListenAndServe(":8099", nil)
+func (s *Server) listHandler(w http.ResponseWriter, r *http.Request) {
+	s.renderList(w, "")
 }
 
-func listHandler(w http.ResponseWriter, r *http.Request) {
-	fmt.Fprint(w, "<h1>Withdrawal Approval</h1>"+"<a href=\"/list\">Refresh</a>"+
-		"<h3>All withdrawal requests:</h3><table><tr><th>ID</th><th>Sports</th><th>Casino</th><th>Manual</th><th>Payment</th><th>Action</th>")
+// renderList writes the reviewer console, with an optional notice above the table.
+func (s *Server) renderList(w http.ResponseWriter, notice string) {
+	fmt.Fprint(w, "<h1>Withdrawal Approval</h1>"+"<a href=\"/list\">Refresh</a>")
+	if notice != "" {
+		fmt.Fprintf(w, "<p>%s</p>", html.EscapeString(notice))
+	}
+	fmt.Fprint(w, "<h3>All withdrawal requests:</h3><form method=\"post\" action=\"/bulk\">"+
+		"<table><tr><th></th><th>ID</th><th>Sports</th><th>Casino</th><th>Manual</th><th>Payment</th><th>Action</th>")
 	keys := []string{}
 	for k := range withdrawal.DB {
 		keys = append(keys, k)
@@ -50,17 +95,33 @@ func listHandler(w http.ResponseWriter, r *http.Request) {
 	sort.Strings(keys)
 	for _, id := range keys {
 		wd := withdrawal.DB[id]
+		checkbox := ""
 		actionLink := ""
 		if wd.State() == withdrawal.Pending {
-			actionLink = fmt.Sprintf("<a href=\"/action?type=approve&domain=manual&id=%s\">"+
-				"<button style=\"background-color:#4CAF50;\">APPROVE</button></a>"+
-				"&nbsp;&nbsp;<a href=\"/action?type=reject&domain=manual&id=%s\">"+
-				"<button style=\"background-color:#f44336;\">REJECT</button></a>", id, id)
+			checkbox = fmt.Sprintf("<input type=\"checkbox\" name=\"id\" value=\"%s\">", id)
+			// the links carry no reviewer, approvals that need one go through the form below the table
+			approve := fmt.Sprintf("<a href=\"/action?type=approve&domain=manual&id=%s\">"+
+				"<button type=\"button\" style=\"background-color:#4CAF50;\">APPROVE</button></a>", id)
+			if s.Rules.NeedsSecondApproval(wd) {
+				approve = "<button type=\"button\" disabled title=\"Needs a reviewer, select it and approve below.\">" +
+					"APPROVE</button>"
+			}
+			actionLink = approve + fmt.Sprintf("&nbsp;&nbsp;<a href=\"/action?type=reject&domain=manual&id=%s\">"+
+				"<button type=\"button\" style=\"background-color:#f44336;\">REJECT</button></a>", id)
 		}
-		fmt.Fprintf(w, "<tr><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td></tr>",
-			id, c(wd.DomainState(withdrawal.Sports)), c(wd.DomainState(withdrawal.Casino)), c(wd.DomainState(withdrawal.Manual)), wd.State(), actionLink)
+		manual := c(wd.DomainState(withdrawal.Manual))
+		if first, ok := wd.FirstApproval(); ok {
+			manual += " (approved by " + html.EscapeString(first.Reviewer) + ", needs a second reviewer)"
+		}
+		fmt.Fprintf(w, "<tr><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td></tr>",
+			checkbox, id, c(wd.DomainState(withdrawal.Sports)), c(wd.DomainState(withdrawal.Casino)), manual, wd.State(), actionLink)
 	}
-	fmt.Fprint(w, "</table>")
+	fmt.Fprint(w, "</table><p>Selected: "+
+		"<input type=\"text\" name=\"reviewer\" placeholder=\"Reviewer\">&nbsp;&nbsp;"+
+		"<input type=\"text\" name=\"reason\" placeholder=\"Reason\" size=\"40\">&nbsp;&nbsp;"+
+		"<button type=\"submit\" name=\"type\" value=\"approve\" style=\"background-color:#4CAF50;\">APPROVE</button>"+
+		"&nbsp;&nbsp;<button type=\"submit\" name=\"type\" value=\"reject\" style=\"background-color:#f44336;\">REJECT</button>"+
+		"</p></form>")
 }
 
 func c(s withdrawal.State) string {
@@ -73,45 +134,82 @@ func c(s withdrawal.State) string {
 	return s.String()
 }
 
-func actionHandler(w http.ResponseWriter, r *http.Request) {
+func (s *Server) actionHandler(w http.ResponseWriter, r *http.Request) {
 	isAPICall := r.URL.Query().Get("is_api_call") == "true"
 	id := r.URL.Query().Get("id")
-	wd, ok := withdrawal.DB[id]
-	if !ok {
+	if _, ok := withdrawal.DB[id]; !ok {
 		fmt.Fprint(w, "ERROR:INVALID_ID")
 		return
 	}
-	oldState := wd.State()
-	action := withdrawal.ParseAction(r.URL.Query().Get("type"))
-	domain := withdrawal.ParseDomain(r.URL.Query().Get("domain"))
 
-	log.Println("received ----> ", action, domain)
+	result := s.applyAction(r.Context(), id, r.URL.Query().Get("type"), r.URL.Query().Get("domain"),
+		r.URL.Query().Get("reviewer"), r.URL.Query().Get("reason"))
 
-	switch action {
-	case withdrawal.Approve:
-		withdrawal.DB[id].Approve(domain)
-	case withdrawal.Reject:
-		withdrawal.DB[id].Reject(domain)
-	case withdrawal.Payout:
-		withdrawal.DB[id].Payout()
-	}
 	if isAPICall {
-		fmt.Fprint(w, "SUCCEED")
+		if ref := withdrawal.DB[id].PayoutRef(); ref != "" {
+			w.Header().Set(withdrawal.PayoutReferenceHeader, ref)
+		}
+		fmt.Fprint(w, result)
+	} else if result != "SUCCEED" {
+		s.renderList(w, id+": "+result)
 	} else {
-		listHandler(w, r)
+		s.listHandler(w, r)
 	}
+	return
+}
 
-	if oldState == withdrawal.Pending && (withdrawal.DB[id].State() == withdrawal.Approved || withdrawal.DB[id].State() == withdrawal.Rejected) {
-		// report state change
-		notifyWithdrawalStateChange(id, withdrawal.DB[id].State().String())
+// applyAction runs a single decision through the withdrawal state machine and
+// reports the resulting state change to the waiting workflow and to webhook
+// subscribers. Every approve, reject and payout goes through here, manual
+// decisions through the four-eyes rules. It returns the reply of the action.
+func (s *Server) applyAction(ctx context.Context, id, actionType, domainName, reviewer, reason string) string {
+	wd := withdrawal.DB[id]
+	oldState := wd.State()
+	action := withdrawal.ParseAction(actionType)
+	domain := withdrawal.ParseDomain(domainName)
+
+	logger := common.Logger(ctx)
+	logger.Debug("Action received.", zap.String("Action", string(action)), zap.String("Reviewer", reviewer))
+
+	switch {
+	case domain == withdrawal.Manual && (action == withdrawal.Approve || action == withdrawal.Reject):
+		changed, err := wd.Review(s.Rules, action, reviewer, reason)
+		if err != nil {
+			logger.Info("Decision refused.", zap.String("Action", string(action)), zap.String("Reviewer", reviewer),
+				zap.Error(err))
+			return "ERROR:" + err.Error()
+		}
+		if _, waiting := wd.FirstApproval(); !changed && waiting {
+			logger.Info("Waiting for a second approval.", zap.String("Reviewer", reviewer))
+		}
+	case action == withdrawal.Approve || action == withdrawal.Reject:
+		wd.Decide(domain, action, reviewer, reason)
+	case action == withdrawal.Payout:
+		wd.Payout()
 	}
 
-	log.Printf("Set state for %s from %s to %s via %v.\n", id, oldState, withdrawal.DB[id].State().String(), domain)
+	if oldState == withdrawal.Pending && (wd.State() == withdrawal.Approved || wd.State() == withdrawal.Rejected) {
+		// report state change
+		s.notifyWithdrawalStateChange(ctx, id, wd.State().String())
+		if wd.State() == withdrawal.Approved {
+			s.publishEvent(ctx, id, webhook.Approved, string(domain))
+		} else {
+			s.publishEvent(ctx, id, webhook.Rejected, string(domain))
+		}
+	}
+	if oldState != withdrawal.Completed && wd.State() == withdrawal.Completed {
+		s.publishEvent(ctx, id, webhook.Completed, string(domain))
+	}
 
-	return
+	s.recordAction(string(domain), string(action))
+	logger.Info("State set.", zap.String("From", oldState.String()), zap.String("To", wd.State().String()),
+		zap.String("Action", string(action)))
+	return "SUCCEED"
 }
 
-func createHandler(w http.ResponseWriter, r *http.Request) {
+// createHandler creates a pending withdrawal. The customer and the amount are optional, withdrawals created by the
+// workflow itself have neither.
+func (s *Server) createHandler(w http.ResponseWriter, r *http.Request) {
 	isAPICall := r.URL.Query().Get("is_api_call") == "true"
 	id := r.URL.Query().Get("id")
 	_, ok := withdrawal.DB[id]
@@ -119,18 +217,29 @@ func createHandler(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprint(w, "ERROR:ID_ALREADY_EXISTS")
 		return
 	}
+	amount := r.URL.Query().Get("amount")
+	if amount != "" {
+		if v, err := strconv.ParseFloat(amount, 64); err != nil || v <= 0 {
+			fmt.Fprint(w, "ERROR:INVALID_AMOUNT")
+			return
+		}
+	}
 
-	withdrawal.DB[id] = withdrawal.New(id)
+	wd := withdrawal.New(id)
+	wd.SetDetails(r.URL.Query().Get("customer"), amount)
+	withdrawal.DB[id] = wd
+	s.updateQueueDepth()
 	if isAPICall {
 		fmt.Fprint(w, "SUCCEED")
 	} else {
-		listHandler(w, r)
+		s.listHandler(w, r)
 	}
-	log.Printf("pending new withdrawal id:%s.\n", id)
+	s.publishEvent(r.Context(), id, webhook.Created, "")
+	common.Logger(r.Context()).Info("Withdrawal pending.")
 	return
 }
 
-func statusHandler(w http.ResponseWriter, r *http.Request) {
+func (s *Server) statusHandler(w http.ResponseWriter, r *http.Request) {
 	id := r.URL.Query().Get("id")
 	wd, ok := withdrawal.DB[id]
 	if !ok {
@@ -139,11 +248,22 @@ func statusHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	fmt.Fprint(w, wd.State().String())
-	log.Printf("Checking status for %s: %s\n", id, wd.State().String())
+	common.Logger(r.Context()).Debug("Status checked.", zap.String("WithdrawalStatus", wd.State().String()))
 	return
 }
 
-func callbackHandler(w http.ResponseWriter, r *http.Request) {
+// withdrawalHandler describes a single withdrawal as json.
+func (s *Server) withdrawalHandler(w http.ResponseWriter, r *http.Request) {
+	wd, ok := withdrawal.DB[r.URL.Query().Get("id")]
+	if !ok {
+		fmt.Fprint(w, "ERROR:INVALID_ID")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(wd.Record())
+}
+
+func (s *Server) callbackHandler(w http.ResponseWriter, r *http.Request) {
 	id := r.URL.Query().Get("id")
 	wd, ok := withdrawal.DB[id]
 	if !ok {
@@ -163,21 +283,22 @@ func callbackHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	taskToken := r.PostFormValue("task_token")
-	log.Printf("Registered callback for ID=%s, token=%s\n", id, taskToken)
-	tokenMap[id] = []byte(taskToken)
+	common.Logger(r.Context()).Info("Callback registered.")
+	s.tokens[id] = []byte(taskToken)
 	fmt.Fprint(w, "SUCCEED")
 }
 
-func notifyWithdrawalStateChange(id, state string) {
-	token, ok := tokenMap[id]
+func (s *Server) notifyWithdrawalStateChange(ctx context.Context, id, state string) {
+	logger := common.Logger(ctx)
+	token, ok := s.tokens[id]
 	if !ok {
-		log.Printf("Invalid id:%s\n", id)
+		logger.Warn("No callback registered.")
 		return
 	}
-	err := workflowClient.CompleteActivity(context.Background(), token, state, nil)
+	err := s.Cadence.CompleteActivity(ctx, token, state, nil)
 	if err != nil {
-		log.Printf("Failed to complete activity with error: %+v\n", err)
+		logger.Error("Failed to complete activity.", zap.Error(err))
 	} else {
-		log.Printf("Successfully complete activity: %s\n", token)
+		logger.Info("Completed activity.", zap.String("WithdrawalStatus", state))
 	}
 }
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	apiclient "github.com/bartke/cadence-withdrawal-approval/api/client"
+	"github.com/bartke/cadence-withdrawal-approval/withdrawal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListActions(t *testing.T) {
+	c, _, cleanup := newTestServer(t, withdrawal.Rules{SecondApprovalAbove: 1000})
+	defer cleanup()
+	ctx := context.Background()
+	for id, amount := range map[string]string{"list-small": "50", "list-large": "5000"} {
+		require.NoError(t, c.Create(ctx, id, apiclient.Details{Customer: "c1", Amount: amount}))
+	}
+
+	resp, err := http.Get(c.URL("/list"))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	page := string(body)
+	require.Contains(t, page, `/action?type=approve&domain=manual&id=list-small"`)
+	require.Contains(t, page, `/action?type=reject&domain=manual&id=list-small"`)
+	// the large withdrawal needs a reviewer the row links do not carry
+	require.NotContains(t, page, `/action?type=approve&domain=manual&id=list-large"`)
+	require.Contains(t, page, `/action?type=reject&domain=manual&id=list-large"`)
+
+	// an approval without a reviewer is refused
+	resp, err = http.Get(c.URL("/action?type=approve&domain=manual&id=list-large"))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err = ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Contains(t, string(body), "list-large: ERROR:REVIEWER_REQUIRED")
+	requireState(t, c, "list-large", withdrawal.Pending)
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bartke/cadence-withdrawal-approval/common"
+	"github.com/bartke/cadence-withdrawal-approval/webhook"
+	"github.com/bartke/cadence-withdrawal-approval/withdrawal"
+	"github.com/pborman/uuid"
+	"go.uber.org/cadence/client"
+	"go.uber.org/zap"
+)
+
+// publishEvent starts one delivery workflow per matching subscription. The
+// workflow owns retries, so the request handler never blocks on subscribers.
+func (s *Server) publishEvent(ctx context.Context, id, eventType, domain string) {
+	event := webhook.Event{
+		ID:           uuid.New(),
+		Type:         eventType,
+		WithdrawalID: id,
+		State:        withdrawal.DB[id].State().String(),
+		Timestamp:    time.Now(),
+	}
+	if domain != string(withdrawal.UnknownDomain) {
+		event.Domain = domain
+	}
+
+	for _, sub := range webhook.Match(eventType) {
+		workflowOptions := client.StartWorkflowOptions{
+			ID:                              "webhook_" + event.ID + "_" + sub.ID,
+			TaskList:                        s.TaskList,
+			ExecutionStartToCloseTimeout:    2 * time.Hour,
+			DecisionTaskStartToCloseTimeout: time.Minute,
+		}
+		logger := common.Logger(ctx).With(zap.String("Event", eventType), zap.String("Subscription", sub.ID))
+		we, err := s.Cadence.StartWorkflow(ctx, workflowOptions, webhook.DeliveryWorkflow, sub.ID, event)
+		if err != nil {
+			logger.Error("Failed to start webhook delivery.", zap.Error(err))
+			continue
+		}
+		logger.Info("Started webhook delivery.", zap.String("WorkflowID", we.ID), zap.String("RunID", we.RunID))
+	}
+}
+
+func (s *Server) deadLetterHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.deadLetters)
+		return
+	}
+
+	var dl webhook.DeadLetter
+	if err := json.NewDecoder(r.Body).Decode(&dl); err != nil {
+		fmt.Fprint(w, "ERROR:INVALID_DEAD_LETTER")
+		return
+	}
+	s.deadLetters = append(s.deadLetters, dl)
+	fmt.Fprint(w, "SUCCEED")
+	common.Logger(r.Context()).Warn("Dead letter received.", zap.String("Subscription", dl.SubscriptionID),
+		zap.String("EventID", dl.Event.ID), zap.String("WithdrawalID", dl.Event.WithdrawalID), zap.String("Error", dl.Error))
+}
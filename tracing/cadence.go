@@ -0,0 +1,64 @@
+package tracing
+
+import (
+	"context"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"go.uber.org/cadence/workflow"
+)
+
+// ActivityPropagator links activity spans to the workflow that scheduled them. Cadence carries the span context of
+// the workflow into the activity headers, but starts activity spans without a parent. The propagator puts the
+// carried span context into the activity context where cadence looks for the parent. Worker options need both the
+// tracer and the propagator, the tracer adds the propagator of cadence that writes the headers.
+func ActivityPropagator(tracer opentracing.Tracer) workflow.ContextPropagator {
+	return activityPropagator{tracer}
+}
+
+type activityPropagator struct {
+	tracer opentracing.Tracer
+}
+
+// Inject leaves the headers to the propagator of cadence.
+func (p activityPropagator) Inject(ctx context.Context, hw workflow.HeaderWriter) error {
+	return nil
+}
+
+// Extract puts the span context of the headers into ctx as the parent of the activity span.
+func (p activityPropagator) Extract(ctx context.Context, hr workflow.HeaderReader) (context.Context, error) {
+	sc, err := p.tracer.Extract(opentracing.TextMap, headerReader{hr})
+	if err != nil {
+		return ctx, nil
+	}
+	return opentracing.ContextWithSpan(ctx, remoteSpan{opentracing.NoopTracer{}.StartSpan(""), sc}), nil
+}
+
+// InjectFromWorkflow leaves the headers to the propagator of cadence.
+func (p activityPropagator) InjectFromWorkflow(ctx workflow.Context, hw workflow.HeaderWriter) error {
+	return nil
+}
+
+// ExtractToWorkflow leaves the workflow context to the propagator of cadence.
+func (p activityPropagator) ExtractToWorkflow(ctx workflow.Context, hr workflow.HeaderReader) (workflow.Context, error) {
+	return ctx, nil
+}
+
+type headerReader struct {
+	hr workflow.HeaderReader
+}
+
+func (r headerReader) ForeachKey(handler func(key, val string) error) error {
+	return r.hr.ForEachKey(func(key string, value []byte) error {
+		return handler(key, string(value))
+	})
+}
+
+// remoteSpan stands for a span of another process, only its context is used.
+type remoteSpan struct {
+	opentracing.Span
+	context opentracing.SpanContext
+}
+
+func (s remoteSpan) Context() opentracing.SpanContext {
+	return s.context
+}
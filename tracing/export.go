@@ -0,0 +1,233 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	opentracing "github.com/opentracing/opentracing-go"
+)
+
+// Exporters of Config.
+const (
+	ExporterNone   = ""
+	ExporterStdout = "stdout"
+	ExporterZipkin = "zipkin"
+)
+
+// zipkin exporter batching.
+const (
+	batchSize     = 100
+	batchInterval = time.Second
+	queueSize     = 1000
+)
+
+// Config selects where spans go, collector is the zipkin v2 spans url for the zipkin exporter, e.g.
+// http://localhost:9411/api/v2/spans.
+type Config struct {
+	Exporter  string `yaml:"exporter"`
+	Collector string `yaml:"collector"`
+}
+
+// SpanData is a finished span.
+type SpanData struct {
+	TraceID   uint64
+	SpanID    uint64
+	ParentID  uint64
+	Service   string
+	Operation string
+	Start     time.Time
+	Duration  time.Duration
+	Tags      map[string]string
+	Logs      []opentracing.LogRecord
+}
+
+// Exporter sends finished spans somewhere. Close sends what is buffered.
+type Exporter interface {
+	Export(span SpanData)
+	Close() error
+}
+
+// New creates the tracer of service for the configuration, without an exporter it is the noop tracer. Close the
+// closer to export buffered spans before exiting.
+func New(service string, c Config) (opentracing.Tracer, io.Closer, error) {
+	if err := c.Validate(); err != nil {
+		return nil, nil, err
+	}
+	var exporter Exporter
+	switch c.Exporter {
+	case ExporterNone:
+		return opentracing.NoopTracer{}, nopCloser{}, nil
+	case ExporterStdout:
+		exporter = NewWriterExporter(os.Stdout)
+	case ExporterZipkin:
+		exporter = NewZipkinExporter(c.Collector)
+	}
+	return NewTracer(service, exporter), exporter, nil
+}
+
+// Validate checks the exporter and its collector.
+func (c Config) Validate() error {
+	switch c.Exporter {
+	case ExporterNone, ExporterStdout:
+		return nil
+	case ExporterZipkin:
+		if c.Collector == "" {
+			return errors.New("zipkin exporter requires a collector")
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown exporter %q", c.Exporter)
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
+
+// zipkinSpan is the zipkin v2 json model.
+type zipkinSpan struct {
+	TraceID       string            `json:"traceId"`
+	ID            string            `json:"id"`
+	ParentID      string            `json:"parentId,omitempty"`
+	Name          string            `json:"name"`
+	Kind          string            `json:"kind,omitempty"`
+	Timestamp     int64             `json:"timestamp"`
+	Duration      int64             `json:"duration"`
+	LocalEndpoint map[string]string `json:"localEndpoint"`
+	Tags          map[string]string `json:"tags,omitempty"`
+}
+
+func toZipkin(s SpanData) zipkinSpan {
+	z := zipkinSpan{
+		TraceID:       formatID(s.TraceID),
+		ID:            formatID(s.SpanID),
+		Name:          s.Operation,
+		Timestamp:     s.Start.UnixNano() / int64(time.Microsecond),
+		Duration:      int64(s.Duration / time.Microsecond),
+		LocalEndpoint: map[string]string{"serviceName": s.Service},
+		Tags:          s.Tags,
+	}
+	if s.ParentID != 0 {
+		z.ParentID = formatID(s.ParentID)
+	}
+	switch s.Tags["span.kind"] {
+	case "client":
+		z.Kind = "CLIENT"
+	case "server":
+		z.Kind = "SERVER"
+	}
+	return z
+}
+
+// WriterExporter writes every span as a line of zipkin json.
+type WriterExporter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterExporter creates an exporter writing to w.
+func NewWriterExporter(w io.Writer) *WriterExporter {
+	return &WriterExporter{w: w}
+}
+
+// Export writes the span.
+func (e *WriterExporter) Export(span SpanData) {
+	data, err := json.Marshal(toZipkin(span))
+	if err != nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.w.Write(append(data, '\n'))
+}
+
+// Close does nothing, spans are written right away.
+func (e *WriterExporter) Close() error {
+	return nil
+}
+
+// ZipkinExporter posts spans in batches to a zipkin collector. Spans are dropped while the queue is full, tracing
+// never blocks the binaries.
+type ZipkinExporter struct {
+	url    string
+	client *http.Client
+	queue  chan SpanData
+	done   chan struct{}
+	once   sync.Once
+}
+
+// NewZipkinExporter starts an exporter posting to url.
+func NewZipkinExporter(url string) *ZipkinExporter {
+	e := &ZipkinExporter{
+		url:    url,
+		client: &http.Client{Timeout: 5 * time.Second},
+		queue:  make(chan SpanData, queueSize),
+		done:   make(chan struct{}),
+	}
+	go e.run()
+	return e
+}
+
+// Export queues the span.
+func (e *ZipkinExporter) Export(span SpanData) {
+	select {
+	case e.queue <- span:
+	default:
+	}
+}
+
+// Close posts the queued spans and stops the exporter.
+func (e *ZipkinExporter) Close() error {
+	e.once.Do(func() { close(e.queue) })
+	<-e.done
+	return nil
+}
+
+func (e *ZipkinExporter) run() {
+	defer close(e.done)
+	ticker := time.NewTicker(batchInterval)
+	defer ticker.Stop()
+	var batch []zipkinSpan
+	for {
+		select {
+		case span, ok := <-e.queue:
+			if !ok {
+				e.post(batch)
+				return
+			}
+			if batch = append(batch, toZipkin(span)); len(batch) >= batchSize {
+				e.post(batch)
+				batch = nil
+			}
+		case <-ticker.C:
+			e.post(batch)
+			batch = nil
+		}
+	}
+}
+
+func (e *ZipkinExporter) post(batch []zipkinSpan) {
+	if len(batch) == 0 {
+		return
+	}
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return
+	}
+	resp, err := e.client.Post(e.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.Printf("Failed to export %d spans: %v\n", len(batch), err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Printf("Failed to export %d spans: %s\n", len(batch), resp.Status)
+	}
+}
@@ -0,0 +1,89 @@
+package tracing
+
+import (
+	"net/http"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+)
+
+// WithdrawalIDTag tags spans of requests about a withdrawal.
+const WithdrawalIDTag = "withdrawal.id"
+
+// Transport traces requests made with a context holding a span, the span context is sent in the request headers.
+// Requests without a span pass through untraced.
+func Transport(next http.RoundTripper) http.RoundTripper {
+	return roundTripper{next}
+}
+
+type roundTripper struct {
+	next http.RoundTripper
+}
+
+func (t roundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	parent := opentracing.SpanFromContext(r.Context())
+	if parent == nil {
+		return t.next.RoundTrip(r)
+	}
+	tracer := parent.Tracer()
+	span := tracer.StartSpan("HTTP "+r.Method+" "+r.URL.Path, opentracing.ChildOf(parent.Context()), ext.SpanKindRPCClient)
+	defer span.Finish()
+	ext.HTTPMethod.Set(span, r.Method)
+	ext.HTTPUrl.Set(span, r.URL.String())
+	if id := r.URL.Query().Get("id"); id != "" {
+		span.SetTag(WithdrawalIDTag, id)
+	}
+
+	// the headers of r belong to the caller
+	r = r.WithContext(opentracing.ContextWithSpan(r.Context(), span))
+	header := make(http.Header, len(r.Header))
+	for k, v := range r.Header {
+		header[k] = v
+	}
+	r.Header = header
+	tracer.Inject(span.Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(r.Header))
+
+	resp, err := t.next.RoundTrip(r)
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogKV("error", err.Error())
+		return resp, err
+	}
+	ext.HTTPStatusCode.Set(span, uint16(resp.StatusCode))
+	if resp.StatusCode >= http.StatusInternalServerError {
+		ext.Error.Set(span, true)
+	}
+	return resp, nil
+}
+
+// Middleware starts a server span for every request, continuing the trace of the caller if it sent one. Handlers
+// find the span in the request context.
+func Middleware(tracer opentracing.Tracer, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		caller, _ := tracer.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(r.Header))
+		span := tracer.StartSpan("HTTP "+r.Method+" "+r.URL.Path, ext.RPCServerOption(caller))
+		defer span.Finish()
+		ext.HTTPMethod.Set(span, r.Method)
+		ext.HTTPUrl.Set(span, r.URL.String())
+		if id := r.URL.Query().Get("id"); id != "" {
+			span.SetTag(WithdrawalIDTag, id)
+		}
+
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r.WithContext(opentracing.ContextWithSpan(r.Context(), span)))
+		ext.HTTPStatusCode.Set(span, uint16(sw.status))
+		if sw.status >= http.StatusInternalServerError {
+			ext.Error.Set(span, true)
+		}
+	})
+}
+
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,277 @@
+// Package tracing is a small opentracing tracer for the withdrawal binaries. Spans are propagated in B3 headers and
+// exported to stdout or to a zipkin compatible collector such as the jaeger all-in-one image.
+package tracing
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/log"
+)
+
+// B3 propagation headers, baggage items are sent with baggagePrefix.
+const (
+	traceIDHeader = "x-b3-traceid"
+	spanIDHeader  = "x-b3-spanid"
+	sampledHeader = "x-b3-sampled"
+	baggagePrefix = "ot-baggage-"
+)
+
+// Tracer creates spans and hands finished spans to its exporter.
+type Tracer struct {
+	service  string
+	exporter Exporter
+
+	mu     sync.Mutex
+	random *rand.Rand
+}
+
+// NewTracer creates a tracer for service.
+func NewTracer(service string, exporter Exporter) *Tracer {
+	return &Tracer{service: service, exporter: exporter, random: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+func (t *Tracer) newID() uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for {
+		if id := t.random.Uint64(); id != 0 {
+			return id
+		}
+	}
+}
+
+// StartSpan starts a span, the first reference to a span of this tracer is its parent.
+func (t *Tracer) StartSpan(operationName string, opts ...opentracing.StartSpanOption) opentracing.Span {
+	var o opentracing.StartSpanOptions
+	for _, opt := range opts {
+		opt.Apply(&o)
+	}
+	s := &Span{tracer: t, operation: operationName, start: o.StartTime, tags: map[string]interface{}{}}
+	if s.start.IsZero() {
+		s.start = time.Now()
+	}
+	for _, ref := range o.References {
+		if parent, ok := ref.ReferencedContext.(SpanContext); ok {
+			s.context = SpanContext{TraceID: parent.TraceID, baggage: parent.baggage}
+			s.parentID = parent.SpanID
+			break
+		}
+	}
+	if s.context.TraceID == 0 {
+		s.context.TraceID = t.newID()
+	}
+	s.context.SpanID = t.newID()
+	for k, v := range o.Tags {
+		s.tags[k] = v
+	}
+	return s
+}
+
+// Inject writes the span context as B3 headers, the text map and http header formats are the same.
+func (t *Tracer) Inject(sm opentracing.SpanContext, format interface{}, carrier interface{}) error {
+	sc, ok := sm.(SpanContext)
+	if !ok {
+		return opentracing.ErrInvalidSpanContext
+	}
+	if format != opentracing.TextMap && format != opentracing.HTTPHeaders {
+		return opentracing.ErrUnsupportedFormat
+	}
+	w, ok := carrier.(opentracing.TextMapWriter)
+	if !ok {
+		return opentracing.ErrInvalidCarrier
+	}
+	w.Set(traceIDHeader, formatID(sc.TraceID))
+	w.Set(spanIDHeader, formatID(sc.SpanID))
+	w.Set(sampledHeader, "1")
+	for k, v := range sc.baggage {
+		w.Set(baggagePrefix+k, v)
+	}
+	return nil
+}
+
+// Extract reads a span context written by Inject, header names are matched case insensitive.
+func (t *Tracer) Extract(format interface{}, carrier interface{}) (opentracing.SpanContext, error) {
+	if format != opentracing.TextMap && format != opentracing.HTTPHeaders {
+		return nil, opentracing.ErrUnsupportedFormat
+	}
+	r, ok := carrier.(opentracing.TextMapReader)
+	if !ok {
+		return nil, opentracing.ErrInvalidCarrier
+	}
+	var sc SpanContext
+	var err error
+	r.ForeachKey(func(key, value string) error {
+		switch key = strings.ToLower(key); {
+		case key == traceIDHeader:
+			sc.TraceID, err = strconv.ParseUint(value, 16, 64)
+		case key == spanIDHeader:
+			sc.SpanID, err = strconv.ParseUint(value, 16, 64)
+		case strings.HasPrefix(key, baggagePrefix):
+			sc = sc.withBaggage(strings.TrimPrefix(key, baggagePrefix), value)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, opentracing.ErrSpanContextCorrupted
+	}
+	if sc.TraceID == 0 || sc.SpanID == 0 {
+		return nil, opentracing.ErrSpanContextNotFound
+	}
+	return sc, nil
+}
+
+func formatID(id uint64) string {
+	return fmt.Sprintf("%016x", id)
+}
+
+// SpanContext identifies a span across process boundaries.
+type SpanContext struct {
+	TraceID uint64
+	SpanID  uint64
+	baggage map[string]string
+}
+
+// ForeachBaggageItem calls handler for every baggage item until it returns false.
+func (c SpanContext) ForeachBaggageItem(handler func(k, v string) bool) {
+	for k, v := range c.baggage {
+		if !handler(k, v) {
+			return
+		}
+	}
+}
+
+func (c SpanContext) withBaggage(key, value string) SpanContext {
+	baggage := make(map[string]string, len(c.baggage)+1)
+	for k, v := range c.baggage {
+		baggage[k] = v
+	}
+	baggage[key] = value
+	c.baggage = baggage
+	return c
+}
+
+// Span is a span of Tracer, it is exported when finished.
+type Span struct {
+	tracer    *Tracer
+	parentID  uint64
+	operation string
+	start     time.Time
+
+	mu      sync.Mutex
+	context SpanContext
+	tags    map[string]interface{}
+	logs    []opentracing.LogRecord
+}
+
+// Finish exports the span.
+func (s *Span) Finish() {
+	s.FinishWithOptions(opentracing.FinishOptions{})
+}
+
+// FinishWithOptions exports the span with the finish time and logs of opts.
+func (s *Span) FinishWithOptions(opts opentracing.FinishOptions) {
+	finish := opts.FinishTime
+	if finish.IsZero() {
+		finish = time.Now()
+	}
+	s.mu.Lock()
+	s.logs = append(s.logs, opts.LogRecords...)
+	data := SpanData{
+		TraceID:   s.context.TraceID,
+		SpanID:    s.context.SpanID,
+		ParentID:  s.parentID,
+		Service:   s.tracer.service,
+		Operation: s.operation,
+		Start:     s.start,
+		Duration:  finish.Sub(s.start),
+		Tags:      make(map[string]string, len(s.tags)),
+		Logs:      s.logs,
+	}
+	for k, v := range s.tags {
+		data.Tags[k] = fmt.Sprint(v)
+	}
+	s.mu.Unlock()
+	s.tracer.exporter.Export(data)
+}
+
+// Context returns the span context.
+func (s *Span) Context() opentracing.SpanContext {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.context
+}
+
+// SetOperationName renames the span.
+func (s *Span) SetOperationName(operationName string) opentracing.Span {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.operation = operationName
+	return s
+}
+
+// SetTag sets a tag of the span.
+func (s *Span) SetTag(key string, value interface{}) opentracing.Span {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tags[key] = value
+	return s
+}
+
+// LogFields records fields with the current time.
+func (s *Span) LogFields(fields ...log.Field) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logs = append(s.logs, opentracing.LogRecord{Timestamp: time.Now(), Fields: fields})
+}
+
+// LogKV records alternating keys and values with the current time.
+func (s *Span) LogKV(alternatingKeyValues ...interface{}) {
+	fields, err := log.InterleavedKVToFields(alternatingKeyValues...)
+	if err != nil {
+		fields = []log.Field{log.Error(err)}
+	}
+	s.LogFields(fields...)
+}
+
+// SetBaggageItem sets a baggage item that is propagated to the children of the span.
+func (s *Span) SetBaggageItem(restrictedKey, value string) opentracing.Span {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.context = s.context.withBaggage(restrictedKey, value)
+	return s
+}
+
+// BaggageItem returns a baggage item of the span.
+func (s *Span) BaggageItem(restrictedKey string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.context.baggage[restrictedKey]
+}
+
+// Tracer returns the tracer of the span.
+func (s *Span) Tracer() opentracing.Tracer {
+	return s.tracer
+}
+
+// LogEvent is deprecated, it logs event as the "event" field.
+func (s *Span) LogEvent(event string) {
+	s.LogFields(log.String("event", event))
+}
+
+// LogEventWithPayload is deprecated, it logs event and payload.
+func (s *Span) LogEventWithPayload(event string, payload interface{}) {
+	s.LogFields(log.String("event", event), log.Object("payload", payload))
+}
+
+// Log is deprecated, use LogFields.
+func (s *Span) Log(data opentracing.LogData) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logs = append(s.logs, data.ToLogRecord())
+}
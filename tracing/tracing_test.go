@@ -0,0 +1,128 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/stretchr/testify/require"
+)
+
+// recorder keeps the exported spans.
+type recorder struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+func (r *recorder) Export(span SpanData) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, span)
+}
+
+func (r *recorder) Close() error { return nil }
+
+func (r *recorder) span(operation string) SpanData {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, s := range r.spans {
+		if s.Operation == operation {
+			return s
+		}
+	}
+	return SpanData{}
+}
+
+func TestInjectExtract(t *testing.T) {
+	tracer := NewTracer("test", &recorder{})
+	span := tracer.StartSpan("root")
+	span.SetBaggageItem("withdrawal", "w-1")
+
+	header := http.Header{}
+	require.NoError(t, tracer.Inject(span.Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(header)))
+	require.Equal(t, "1", header.Get("X-B3-Sampled"))
+
+	extracted, err := tracer.Extract(opentracing.TextMap, opentracing.HTTPHeadersCarrier(header))
+	require.NoError(t, err)
+	require.Equal(t, span.Context(), extracted)
+
+	_, err = tracer.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(http.Header{}))
+	require.Equal(t, opentracing.ErrSpanContextNotFound, err)
+}
+
+func TestHTTPPropagation(t *testing.T) {
+	spans := &recorder{}
+	tracer := NewTracer("test", spans)
+	server := httptest.NewServer(Middleware(tracer, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NotNil(t, opentracing.SpanFromContext(r.Context()))
+		w.WriteHeader(http.StatusAccepted)
+	})))
+	defer server.Close()
+
+	root := tracer.StartSpan("activity")
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/action?id=w-1", nil)
+	require.NoError(t, err)
+	client := &http.Client{Transport: Transport(http.DefaultTransport)}
+	resp, err := client.Do(req.WithContext(opentracing.ContextWithSpan(context.Background(), root)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	root.Finish()
+	require.Empty(t, req.Header, "the request of the caller is not modified")
+
+	rootData := spans.span("activity")
+	var clientData, serverData SpanData
+	for _, s := range spans.spans {
+		if s.Operation == "HTTP GET /action" && s.Tags["span.kind"] == "server" {
+			serverData = s
+		}
+		if s.Operation == "HTTP GET /action" && s.Tags["span.kind"] == "client" {
+			clientData = s
+		}
+	}
+	require.Len(t, spans.spans, 3)
+	require.Equal(t, rootData.TraceID, clientData.TraceID)
+	require.Equal(t, rootData.TraceID, serverData.TraceID)
+	require.Equal(t, rootData.SpanID, clientData.ParentID)
+	require.Equal(t, clientData.SpanID, serverData.ParentID)
+	require.Equal(t, "w-1", serverData.Tags[WithdrawalIDTag])
+	require.Equal(t, "202", serverData.Tags["http.status_code"])
+}
+
+// header is a cadence header.
+type header map[string][]byte
+
+func (h header) Set(key string, value []byte) { h[key] = value }
+
+func (h header) ForEachKey(handler func(string, []byte) error) error {
+	for k, v := range h {
+		if err := handler(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestActivityPropagator(t *testing.T) {
+	tracer := NewTracer("test", &recorder{})
+	workflowSpan := tracer.StartSpan("StartWorkflow")
+
+	h := header{}
+	writer := opentracing.TextMapCarrier{}
+	require.NoError(t, tracer.Inject(workflowSpan.Context(), opentracing.TextMap, writer))
+	for k, v := range writer {
+		h.Set(k, []byte(v))
+	}
+
+	ctx, err := ActivityPropagator(tracer).Extract(context.Background(), h)
+	require.NoError(t, err)
+	parent := opentracing.SpanFromContext(ctx)
+	require.NotNil(t, parent)
+	require.Equal(t, workflowSpan.Context(), parent.Context())
+
+	ctx, err = ActivityPropagator(tracer).Extract(context.Background(), header{})
+	require.NoError(t, err)
+	require.Nil(t, opentracing.SpanFromContext(ctx))
+}
@@ -0,0 +1,135 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"go.uber.org/cadence"
+	"go.uber.org/cadence/activity"
+	"go.uber.org/cadence/workflow"
+	"go.uber.org/zap"
+)
+
+// This is registration process where you register the delivery workflow and its activities.
+func init() {
+	workflow.Register(DeliveryWorkflow)
+	activity.Register(deliverActivity)
+	activity.Register(deadLetterActivity)
+}
+
+// ErrUnknownSubscription is the reason used when the worker does not know a
+// subscription; retrying cannot fix that.
+const ErrUnknownSubscription = "UNKNOWN_SUBSCRIPTION"
+
+// DeadLetterURL receives deliveries that exhausted their retries.
+var DeadLetterURL = "http://localhost:8099/webhooks/deadletter"
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// DeadLetter records an event that could not be delivered.
+type DeadLetter struct {
+	SubscriptionID string    `json:"subscription_id"`
+	Event          Event     `json:"event"`
+	Error          string    `json:"error"`
+	FailedAt       time.Time `json:"failed_at"`
+}
+
+// DeliveryWorkflow delivers a single event to a single subscription. Delivery
+// is retried with backoff for up to an hour, after which a dead letter is
+// recorded with the withdrawal server.
+func DeliveryWorkflow(ctx workflow.Context, subscriptionID string, event Event) error {
+	ao := workflow.ActivityOptions{
+		ScheduleToStartTimeout: time.Minute,
+		StartToCloseTimeout:    30 * time.Second,
+		RetryPolicy: &cadence.RetryPolicy{
+			InitialInterval:          time.Second,
+			BackoffCoefficient:       2.0,
+			MaximumInterval:          10 * time.Minute,
+			ExpirationInterval:       time.Hour,
+			NonRetriableErrorReasons: []string{ErrUnknownSubscription},
+		},
+	}
+	ctx1 := workflow.WithActivityOptions(ctx, ao)
+	logger := workflow.GetLogger(ctx)
+
+	err := workflow.ExecuteActivity(ctx1, deliverActivity, subscriptionID, event).Get(ctx1, nil)
+	if err == nil {
+		return nil
+	}
+	logger.Warn("Webhook delivery exhausted retries.", zap.String("Subscription", subscriptionID), zap.Error(err))
+
+	dl := DeadLetter{
+		SubscriptionID: subscriptionID,
+		Event:          event,
+		Error:          err.Error(),
+		FailedAt:       workflow.Now(ctx),
+	}
+	if dlErr := workflow.ExecuteActivity(ctx1, deadLetterActivity, dl).Get(ctx1, nil); dlErr != nil {
+		logger.Error("Failed to record dead letter.", zap.Error(dlErr))
+	}
+	return err
+}
+
+func deliverActivity(ctx context.Context, subscriptionID string, event Event) error {
+	sub, ok := Lookup(subscriptionID)
+	if !ok {
+		return cadence.NewCustomError(ErrUnknownSubscription, subscriptionID)
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, event.Type)
+	req.Header.Set(DeliveryHeader, event.ID+"_"+sub.ID)
+	req.Header.Set(SignatureHeader, Sign(sub.Secret, body))
+
+	resp, err := httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		activity.GetLogger(ctx).Info("Webhook delivery failed.",
+			zap.String("Subscription", sub.ID), zap.Int("StatusCode", resp.StatusCode))
+		return fmt.Errorf("webhook %s responded with status %d", sub.ID, resp.StatusCode)
+	}
+
+	activity.GetLogger(ctx).Info("Webhook delivered.",
+		zap.String("Subscription", sub.ID), zap.String("Event", event.Type), zap.String("WithdrawalID", event.WithdrawalID))
+	return nil
+}
+
+func deadLetterActivity(ctx context.Context, dl DeadLetter) error {
+	body, err := json.Marshal(dl)
+	if err != nil {
+		return err
+	}
+	resp, err := httpClient.Post(DeadLetterURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	reply, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	if string(reply) != "SUCCEED" {
+		return errors.New(string(reply))
+	}
+	return nil
+}
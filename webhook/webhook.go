@@ -0,0 +1,114 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Lifecycle events published for a withdrawal.
+const (
+	Created   = "withdrawal.created"
+	Approved  = "withdrawal.approved"
+	Rejected  = "withdrawal.rejected"
+	Completed = "withdrawal.completed"
+)
+
+const (
+	// SignatureHeader carries the HMAC-SHA256 of the request body, "sha256=<hex>".
+	SignatureHeader = "X-Withdrawal-Signature"
+	// EventHeader carries the event type so receivers can route without parsing.
+	EventHeader = "X-Withdrawal-Event"
+	// DeliveryHeader is unique per delivery and stable across retries.
+	DeliveryHeader = "X-Withdrawal-Delivery"
+
+	signaturePrefix = "sha256="
+)
+
+// Event is the JSON payload posted to subscribers.
+type Event struct {
+	ID           string    `json:"id"`
+	Type         string    `json:"type"`
+	WithdrawalID string    `json:"withdrawal_id"`
+	State        string    `json:"state"`
+	Domain       string    `json:"domain,omitempty"`
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+// Subscription is a configured webhook endpoint. An empty Events list
+// subscribes to all lifecycle events.
+type Subscription struct {
+	ID     string   `yaml:"id"`
+	URL    string   `yaml:"url"`
+	Secret string   `yaml:"secret"`
+	Events []string `yaml:"events"`
+}
+
+// Matches reports whether the subscription wants events of the given type.
+func (s Subscription) Matches(eventType string) bool {
+	if len(s.Events) == 0 {
+		return true
+	}
+	for _, e := range s.Events {
+		if e == eventType || e == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// subscriptions are kept in process and looked up by id, so secrets never
+// end up in workflow histories.
+var (
+	mu            sync.RWMutex
+	subscriptions = make(map[string]Subscription)
+)
+
+// Register makes subscriptions known to this process. Both the publishing
+// server and the delivering worker need the same set.
+func Register(subs ...Subscription) {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, s := range subs {
+		subscriptions[s.ID] = s
+	}
+}
+
+// Lookup returns the subscription registered under id.
+func Lookup(id string) (Subscription, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	s, ok := subscriptions[id]
+	return s, ok
+}
+
+// Match returns all subscriptions interested in eventType.
+func Match(eventType string) []Subscription {
+	mu.RLock()
+	defer mu.RUnlock()
+	var subs []Subscription
+	for _, s := range subscriptions {
+		if s.Matches(eventType) {
+			subs = append(subs, s)
+		}
+	}
+	return subs
+}
+
+// Sign returns the signature header value for body.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a signature header value against body in constant time.
+func Verify(secret string, body []byte, signature string) bool {
+	if !strings.HasPrefix(signature, signaturePrefix) {
+		return false
+	}
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
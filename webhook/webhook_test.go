@@ -0,0 +1,74 @@
+package webhook
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/cadence/testsuite"
+)
+
+type UnitTestSuite struct {
+	suite.Suite
+	testsuite.WorkflowTestSuite
+}
+
+func TestUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(UnitTestSuite))
+}
+
+func (s *UnitTestSuite) Test_SignAndVerify() {
+	body := []byte(`{"id":"1"}`)
+	sig := Sign("secret", body)
+
+	s.True(Verify("secret", body, sig))
+	s.False(Verify("other", body, sig))
+	s.False(Verify("secret", []byte(`{"id":"2"}`), sig))
+	s.False(Verify("secret", body, sig[len(signaturePrefix):]))
+}
+
+func (s *UnitTestSuite) Test_SubscriptionMatches() {
+	s.True(Subscription{}.Matches(Created))
+	s.True(Subscription{Events: []string{Approved, Rejected}}.Matches(Rejected))
+	s.False(Subscription{Events: []string{Approved}}.Matches(Completed))
+}
+
+func (s *UnitTestSuite) Test_DeliveryIsSigned() {
+	var got *http.Request
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer server.Close()
+	Register(Subscription{ID: "signed", URL: server.URL, Secret: "secret"})
+
+	env := s.NewTestWorkflowEnvironment()
+	env.ExecuteWorkflow(DeliveryWorkflow, "signed", Event{ID: "e1", Type: Approved, WithdrawalID: "w1"})
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	s.Equal(Approved, got.Header.Get(EventHeader))
+	s.Equal("e1_signed", got.Header.Get(DeliveryHeader))
+	s.True(Verify("secret", body, got.Header.Get(SignatureHeader)))
+}
+
+func (s *UnitTestSuite) Test_DeliveryDeadLetter() {
+	env := s.NewTestWorkflowEnvironment()
+	env.SetTestTimeout(10 * time.Second)
+	env.OnActivity(deliverActivity, mock.Anything, "down", mock.Anything).Return(errors.New("connection refused"))
+	env.OnActivity(deadLetterActivity, mock.Anything, mock.MatchedBy(func(dl DeadLetter) bool {
+		return dl.SubscriptionID == "down" && dl.Event.ID == "e2" && dl.Error == "connection refused"
+	})).Return(nil).Once()
+
+	env.ExecuteWorkflow(DeliveryWorkflow, "down", Event{ID: "e2", Type: Rejected, WithdrawalID: "w2"})
+
+	s.True(env.IsWorkflowCompleted())
+	s.Error(env.GetWorkflowError())
+	env.AssertExpectations(s.T())
+}
@@ -0,0 +1,166 @@
+package withdrawal
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Record is the exported view of a withdrawal for reconciliation and reporting. Each domain carries its state and
+// who decided it when; Reason is the one of the manual review.
+type Record struct {
+	ID              string     `json:"id"`
+	State           string     `json:"state"`
+	Sports          string     `json:"sports"`
+	SportsReviewer  string     `json:"sports_reviewer,omitempty"`
+	SportsDecidedAt *time.Time `json:"sports_decided_at,omitempty"`
+	Casino          string     `json:"casino"`
+	CasinoReviewer  string     `json:"casino_reviewer,omitempty"`
+	CasinoDecidedAt *time.Time `json:"casino_decided_at,omitempty"`
+	Manual          string     `json:"manual"`
+	ManualReviewer  string     `json:"manual_reviewer,omitempty"`
+	ManualDecidedAt *time.Time `json:"manual_decided_at,omitempty"`
+	Reason          string     `json:"reason,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	DecidedAt       *time.Time `json:"decided_at,omitempty"`
+	DecisionLatency float64    `json:"decision_latency_seconds,omitempty"`
+	PaidAt          *time.Time `json:"paid_at,omitempty"`
+	PayoutRef       string     `json:"payout_ref,omitempty"`
+	Customer        string     `json:"customer,omitempty"`
+	Amount          string     `json:"amount,omitempty"`
+}
+
+var csvHeader = []string{
+	"id", "state", "sports", "sports_reviewer", "sports_decided_at", "casino", "casino_reviewer", "casino_decided_at",
+	"manual", "manual_reviewer", "manual_decided_at", "reason",
+	"created_at", "decided_at", "decision_latency_seconds", "paid_at", "payout_ref", "customer", "amount",
+}
+
+func (w *withdrawal) Record() Record {
+	r := Record{
+		ID:        w.id,
+		State:     w.state.String(),
+		Sports:    w.domainState[Sports].String(),
+		Casino:    w.domainState[Casino].String(),
+		Manual:    w.domainState[Manual].String(),
+		CreatedAt: w.createdAt,
+		PayoutRef: w.payoutRef,
+		Customer:  w.customer,
+		Amount:    w.amount,
+	}
+	if d, ok := w.domainDecision(Sports); ok {
+		r.SportsReviewer, r.SportsDecidedAt = d.Reviewer, &d.At
+	}
+	if d, ok := w.domainDecision(Casino); ok {
+		r.CasinoReviewer, r.CasinoDecidedAt = d.Reviewer, &d.At
+	}
+	if d, ok := w.domainDecision(Manual); ok {
+		r.ManualReviewer, r.ManualDecidedAt, r.Reason = d.Reviewer, &d.At, d.Reason
+	}
+	if !w.decidedAt.IsZero() {
+		decidedAt := w.decidedAt
+		r.DecidedAt = &decidedAt
+		r.DecisionLatency = w.decidedAt.Sub(w.createdAt).Seconds()
+	}
+	if !w.paidAt.IsZero() {
+		paidAt := w.paidAt
+		r.PaidAt = &paidAt
+	}
+	return r
+}
+
+// domainDecision returns the decision the state of key is based on, the last one of the domain. Pending domains
+// have none, a first approval that waits for a second reviewer does not count.
+func (w *withdrawal) domainDecision(key domain) (Decision, bool) {
+	if w.domainState[key] == Pending {
+		return Decision{}, false
+	}
+	for i := len(w.decisions) - 1; i >= 0; i-- {
+		if w.decisions[i].Domain == key {
+			return w.decisions[i], true
+		}
+	}
+	return Decision{}, false
+}
+
+// Export returns the records of all withdrawals created in [from, to), oldest first.
+func Export(from, to time.Time) []Record {
+	records := []Record{}
+	for _, w := range DB {
+		if w.createdAt.Before(from) || !w.createdAt.Before(to) {
+			continue
+		}
+		records = append(records, w.Record())
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].CreatedAt.Equal(records[j].CreatedAt) {
+			return records[i].ID < records[j].ID
+		}
+		return records[i].CreatedAt.Before(records[j].CreatedAt)
+	})
+	return records
+}
+
+// WriteCSV writes records with a header row. Times are RFC 3339 in UTC.
+func WriteCSV(w io.Writer, records []Record) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, r := range records {
+		latency := ""
+		if r.DecidedAt != nil {
+			latency = strconv.FormatFloat(r.DecisionLatency, 'f', 3, 64)
+		}
+		err := cw.Write([]string{
+			r.ID, r.State, r.Sports, r.SportsReviewer, formatTime(r.SportsDecidedAt),
+			r.Casino, r.CasinoReviewer, formatTime(r.CasinoDecidedAt),
+			r.Manual, r.ManualReviewer, formatTime(r.ManualDecidedAt), r.Reason,
+			formatTime(&r.CreatedAt), formatTime(r.DecidedAt), latency, formatTime(r.PaidAt), r.PayoutRef,
+			r.Customer, r.Amount,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteJSONLines writes one JSON object per record and line.
+func WriteJSONLines(w io.Writer, records []Record) error {
+	enc := json.NewEncoder(w)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadJSONLines parses the output of WriteJSONLines.
+func ReadJSONLines(r io.Reader) ([]Record, error) {
+	records := []Record{}
+	dec := json.NewDecoder(r)
+	for {
+		var rec Record
+		err := dec.Decode(&rec)
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,76 @@
+package withdrawal
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExport(t *testing.T) {
+	created := time.Date(2019, 7, 1, 12, 0, 0, 0, time.UTC)
+	db, ledger := DB, Ledger
+	defer func() { DB, Ledger = db, ledger }()
+	DB = map[string]*withdrawal{}
+
+	w := New("a")
+	w.createdAt = created
+	w.SetDetails("c1", "25.00")
+	w.Decide(Sports, Approve, "", "")
+	w.Decide(Casino, Reject, "casino-approver", "limit exceeded")
+	w.Decide(Manual, Approve, "alice", "known customer")
+	w.decisions[0].At = created.Add(30 * time.Second)
+	w.decisions[1].At = created.Add(60 * time.Second)
+	w.decisions[2].At = created.Add(90 * time.Second)
+	w.decidedAt = created.Add(90 * time.Second)
+	w.Payout()
+	DB["a"] = w
+
+	old := New("b")
+	old.createdAt = created.AddDate(0, -1, 0)
+	DB["b"] = old
+
+	records := Export(created.Add(-time.Hour), created.Add(time.Hour))
+	require.Len(t, records, 1)
+	r := records[0]
+	require.Equal(t, "COMPLETED", r.State)
+	require.Equal(t, "APPROVED", r.Sports)
+	require.Equal(t, "", r.SportsReviewer)
+	require.Equal(t, created.Add(30*time.Second), *r.SportsDecidedAt)
+	require.Equal(t, "REJECTED", r.Casino)
+	require.Equal(t, "casino-approver", r.CasinoReviewer)
+	require.Equal(t, created.Add(60*time.Second), *r.CasinoDecidedAt)
+	require.Equal(t, "APPROVED", r.Manual)
+	require.Equal(t, "alice", r.ManualReviewer)
+	require.Equal(t, created.Add(90*time.Second), *r.ManualDecidedAt)
+	require.Equal(t, "known customer", r.Reason)
+	require.Equal(t, 90.0, r.DecisionLatency)
+	require.Equal(t, "PO-a", r.PayoutRef)
+	require.Equal(t, "c1", r.Customer)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteCSV(&buf, records))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+	require.Equal(t, strings.Join(csvHeader, ","), lines[0])
+	require.True(t, strings.HasPrefix(lines[1], "a,COMPLETED,"+
+		"APPROVED,,2019-07-01T12:00:30Z,REJECTED,casino-approver,2019-07-01T12:01:00Z,"+
+		"APPROVED,alice,2019-07-01T12:01:30Z,known customer,"+
+		"2019-07-01T12:00:00Z,2019-07-01T12:01:30Z,90.000,"))
+	require.True(t, strings.HasSuffix(lines[1], ",PO-a,c1,25.00"))
+}
+
+func TestExportWaitingFirstApproval(t *testing.T) {
+	w := New("a")
+	w.SetDetails("c1", "5000")
+	changed, err := w.Review(Rules{SecondApprovalAbove: 1000}, Approve, "alice", "")
+	require.NoError(t, err)
+	require.False(t, changed)
+
+	r := w.Record()
+	require.Equal(t, "PENDING", r.Manual)
+	require.Equal(t, "", r.ManualReviewer)
+	require.Nil(t, r.ManualDecidedAt)
+}
@@ -0,0 +1,270 @@
+package withdrawal
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// The tests below run random sequences of decisions and payouts against a withdrawal and check them against a
+// model of the approval policy. The seed is fixed so failures reproduce, the sequences are printed with them.
+const (
+	modelSeed  = 50
+	modelRuns  = 2000
+	maxActions = 12
+)
+
+// step is a decision of a domain, or a payout if the domain is empty.
+type step struct {
+	domain domain
+	action action
+}
+
+func (s step) String() string {
+	if s.domain == "" {
+		return string(Payout)
+	}
+	return string(s.domain) + ":" + string(s.action)
+}
+
+type steps []step
+
+func (s steps) String() string {
+	names := make([]string, len(s))
+	for i, st := range s {
+		names[i] = st.String()
+	}
+	return strings.Join(names, " ")
+}
+
+func randomSteps(r *rand.Rand, payouts bool) steps {
+	domains := []domain{Sports, Casino, Manual}
+	s := make(steps, r.Intn(maxActions+1))
+	for i := range s {
+		if payouts && r.Intn(4) == 0 {
+			s[i] = step{action: Payout}
+			continue
+		}
+		s[i] = step{domain: domains[r.Intn(len(domains))], action: Approve}
+		if r.Intn(2) == 0 {
+			s[i].action = Reject
+		}
+	}
+	return s
+}
+
+// policy is the approval policy as a truth table over the sports, casino and manual states. It is written out
+// instead of calling Evaluate so the model does not share a bug with the code it checks.
+var policy = map[[3]State]State{
+	{Pending, Pending, Pending}:   Pending,
+	{Pending, Approved, Pending}:  Pending,
+	{Pending, Rejected, Pending}:  Pending,
+	{Approved, Pending, Pending}:  Pending,
+	{Approved, Approved, Pending}: Approved,
+	{Approved, Rejected, Pending}: Pending,
+	{Rejected, Pending, Pending}:  Pending,
+	{Rejected, Approved, Pending}: Pending,
+	{Rejected, Rejected, Pending}: Pending,
+
+	{Pending, Pending, Approved}:   Approved,
+	{Pending, Approved, Approved}:  Approved,
+	{Pending, Rejected, Approved}:  Approved,
+	{Approved, Pending, Approved}:  Approved,
+	{Approved, Approved, Approved}: Approved,
+	{Approved, Rejected, Approved}: Approved,
+	{Rejected, Pending, Approved}:  Approved,
+	{Rejected, Approved, Approved}: Approved,
+	{Rejected, Rejected, Approved}: Approved,
+
+	{Pending, Pending, Rejected}:   Rejected,
+	{Pending, Approved, Rejected}:  Rejected,
+	{Pending, Rejected, Rejected}:  Rejected,
+	{Approved, Pending, Rejected}:  Rejected,
+	{Approved, Approved, Rejected}: Rejected,
+	{Approved, Rejected, Rejected}: Rejected,
+	{Rejected, Pending, Rejected}:  Rejected,
+	{Rejected, Approved, Rejected}: Rejected,
+	{Rejected, Rejected, Rejected}: Rejected,
+}
+
+// model is the policy in its simplest form: the first decision of a domain counts, except that the manual
+// review can still reject, and nothing counts once the withdrawal is paid out.
+type model struct {
+	decided map[domain]State
+	paid    bool
+}
+
+func newModel() *model {
+	return &model{decided: map[domain]State{Sports: Pending, Casino: Pending, Manual: Pending}}
+}
+
+func (m *model) apply(s step) {
+	switch {
+	case m.paid:
+	case s.action == Payout:
+		m.paid = m.state() == Approved
+	case s.action == Reject && s.domain == Manual:
+		m.decided[Manual] = Rejected
+	case m.decided[s.domain] != Pending:
+	case s.action == Approve:
+		m.decided[s.domain] = Approved
+	default:
+		m.decided[s.domain] = Rejected
+	}
+}
+
+func (m *model) state() State {
+	if m.paid {
+		return Completed
+	}
+	return policy[[3]State{m.decided[Sports], m.decided[Casino], m.decided[Manual]}]
+}
+
+func (w *withdrawal) apply(s step) {
+	if s.action == Payout {
+		w.Payout()
+		return
+	}
+	w.Decide(s.domain, s.action, "", "")
+}
+
+// isolateLedger empties the global Ledger for a test, the returned function restores it. The tests reuse their
+// withdrawal ids and would otherwise count the payouts of earlier runs.
+func isolateLedger() func() {
+	ledger := Ledger
+	Ledger = nil
+	return func() { Ledger = ledger }
+}
+
+// payouts returns the ledger entries of the withdrawal.
+func (w *withdrawal) payouts() int {
+	n := 0
+	for _, p := range Ledger {
+		if p.WithdrawalID == w.id {
+			n++
+		}
+	}
+	return n
+}
+
+func TestWithdrawalFollowsModel(t *testing.T) {
+	defer isolateLedger()()
+
+	r := rand.New(rand.NewSource(modelSeed))
+	for run := 0; run < modelRuns; run++ {
+		s := randomSteps(r, true)
+		w, m := New(fmt.Sprintf("model-%d", run)), newModel()
+		for i, st := range s {
+			before := w.State()
+			w.apply(st)
+			m.apply(st)
+			msg := fmt.Sprintf("after %d steps of %s", i+1, s)
+
+			require.Equal(t, m.state(), w.State(), msg)
+			for _, d := range []domain{Sports, Casino, Manual} {
+				require.Equal(t, m.decided[d], w.DomainState(d), "%s: %s", d, msg)
+			}
+			// terminal states are absorbing
+			if before == Completed || before == Rejected {
+				require.Equal(t, before, w.State(), msg)
+			}
+			// never paid unless approved, and never paid twice
+			if w.State() == Completed {
+				require.True(t, before == Approved || before == Completed, msg)
+				require.Equal(t, 1, w.payouts(), msg)
+				require.Equal(t, "PO-"+w.ID(), w.PayoutRef(), msg)
+			} else {
+				require.Zero(t, w.payouts(), msg)
+				require.Empty(t, w.PayoutRef(), msg)
+			}
+		}
+	}
+}
+
+func TestManualRejectWins(t *testing.T) {
+	defer isolateLedger()()
+
+	r := rand.New(rand.NewSource(modelSeed))
+	for run := 0; run < modelRuns; run++ {
+		s := randomSteps(r, true)
+		// reject at a random point, the payouts before it decide whether it came too late
+		at := r.Intn(len(s) + 1)
+		s = append(s[:at], append(steps{{Manual, Reject}}, s[at:]...)...)
+
+		w := New(fmt.Sprintf("reject-%d", run))
+		paid := false
+		for i, st := range s {
+			w.apply(st)
+			if i < at && w.State() == Completed {
+				paid = true
+			}
+		}
+		if paid {
+			require.Equal(t, Completed, w.State(), s.String())
+		} else {
+			require.Equal(t, Rejected, w.State(), s.String())
+			require.Equal(t, Rejected, w.DomainState(Manual), s.String())
+		}
+	}
+}
+
+// TestDecisionsAreOrderIndependent checks that the state a withdrawal is decided in does not depend on the order
+// the domains decide in, as long as every automated approver decides at most once.
+func TestDecisionsAreOrderIndependent(t *testing.T) {
+	defer isolateLedger()()
+
+	r := rand.New(rand.NewSource(modelSeed))
+	for run := 0; run < modelRuns; run++ {
+		var s steps
+		for _, d := range []domain{Sports, Casino} {
+			if r.Intn(3) > 0 {
+				s = append(s, step{d, Approve})
+				if r.Intn(2) == 0 {
+					s[len(s)-1].action = Reject
+				}
+			}
+		}
+		for i := r.Intn(3); i > 0; i-- {
+			s = append(s, step{Manual, Approve})
+			if r.Intn(2) == 0 {
+				s[len(s)-1].action = Reject
+			}
+		}
+
+		var want State
+		for i := 0; i < 5; i++ {
+			r.Shuffle(len(s), func(i, j int) { s[i], s[j] = s[j], s[i] })
+			w := New(fmt.Sprintf("order-%d-%d", run, i))
+			for _, st := range s {
+				w.apply(st)
+			}
+			if i == 0 {
+				want = w.State()
+			}
+			require.Equal(t, want, w.State(), s.String())
+		}
+	}
+}
+
+// TestDecisionsAfterPayout checks that a paid out withdrawal ignores late decisions instead of being approved and
+// paid again, or rejected after the money is gone.
+func TestDecisionsAfterPayout(t *testing.T) {
+	defer isolateLedger()()
+
+	w := New("late")
+	w.Decide(Manual, Approve, "alice", "")
+	w.Payout()
+	require.Equal(t, Completed, w.State())
+
+	require.False(t, w.Decide(Sports, Approve, "", ""))
+	require.False(t, w.Decide(Manual, Reject, "bob", "too late"))
+	w.Payout()
+	require.Equal(t, Completed, w.State())
+	require.Equal(t, Approved, w.DomainState(Manual))
+	require.Equal(t, Pending, w.DomainState(Sports))
+	require.Len(t, w.Decisions(), 1)
+	require.Equal(t, 1, w.payouts())
+}
@@ -0,0 +1,70 @@
+package withdrawal
+
+import (
+	"errors"
+	"strconv"
+	"time"
+)
+
+// Rules are the four-eyes rules of manual decisions.
+type Rules struct {
+	// SecondApprovalAbove is the amount above which a manual approval only takes effect once a second reviewer
+	// approved as well. Zero disables the rule.
+	SecondApprovalAbove float64
+}
+
+// Decisions the rules refuse, their messages are the error codes of the server.
+var (
+	ErrReviewerRequired = errors.New("REVIEWER_REQUIRED")
+	ErrSameReviewer     = errors.New("SAME_REVIEWER")
+)
+
+// NeedsSecondApproval reports whether a manual approval of w needs a second reviewer. Withdrawals without an
+// amount never do.
+func (r Rules) NeedsSecondApproval(w *withdrawal) bool {
+	if r.SecondApprovalAbove <= 0 {
+		return false
+	}
+	amount, err := strconv.ParseFloat(w.amount, 64)
+	return err == nil && amount > r.SecondApprovalAbove
+}
+
+// Review applies a manual decision under the rules and reports whether the manual state changed. The first
+// approval of a withdrawal that needs two is recorded without deciding it, the second has to come from another
+// reviewer. Rejections take effect right away.
+func (w *withdrawal) Review(r Rules, a action, reviewer, reason string) (bool, error) {
+	if a != Approve || !r.NeedsSecondApproval(w) || w.domainState[Manual] != Pending || w.state == Completed {
+		return w.Decide(Manual, a, reviewer, reason), nil
+	}
+	if reviewer == "" {
+		return false, ErrReviewerRequired
+	}
+	first, ok := w.FirstApproval()
+	if !ok {
+		w.decisions = append(w.decisions, Decision{
+			Domain:   Manual,
+			Action:   Approve,
+			Reviewer: reviewer,
+			Reason:   reason,
+			At:       time.Now(),
+		})
+		return false, nil
+	}
+	if first.Reviewer == reviewer {
+		return false, ErrSameReviewer
+	}
+	return w.Decide(Manual, Approve, reviewer, reason), nil
+}
+
+// FirstApproval returns the manual approval that waits for a second reviewer.
+func (w *withdrawal) FirstApproval() (Decision, bool) {
+	if w.domainState[Manual] != Pending {
+		return Decision{}, false
+	}
+	for _, d := range w.decisions {
+		if d.Domain == Manual && d.Action == Approve {
+			return d, true
+		}
+	}
+	return Decision{}, false
+}
@@ -0,0 +1,61 @@
+package withdrawal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReview(t *testing.T) {
+	rules := Rules{SecondApprovalAbove: 1000}
+
+	small := New("small")
+	small.SetDetails("c1", "1000")
+	require.False(t, rules.NeedsSecondApproval(small))
+	changed, err := small.Review(rules, Approve, "", "")
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.Equal(t, Approved, small.State())
+
+	large := New("large")
+	large.SetDetails("c1", "1000.01")
+	require.True(t, rules.NeedsSecondApproval(large))
+	_, err = large.Review(rules, Approve, "", "")
+	require.Equal(t, ErrReviewerRequired, err)
+
+	changed, err = large.Review(rules, Approve, "alice", "known customer")
+	require.NoError(t, err)
+	require.False(t, changed)
+	require.Equal(t, Pending, large.State())
+	first, ok := large.FirstApproval()
+	require.True(t, ok)
+	require.Equal(t, "alice", first.Reviewer)
+
+	_, err = large.Review(rules, Approve, "alice", "")
+	require.Equal(t, ErrSameReviewer, err)
+	require.Equal(t, Pending, large.DomainState(Manual))
+
+	changed, err = large.Review(rules, Approve, "bob", "checked")
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.Equal(t, Approved, large.State())
+	_, ok = large.FirstApproval()
+	require.False(t, ok)
+	require.Len(t, large.Decisions(), 2)
+
+	// a rejection needs no second reviewer, even after a first approval
+	rejected := New("rejected")
+	rejected.SetDetails("c1", "5000")
+	rejected.Review(rules, Approve, "alice", "")
+	changed, err = rejected.Review(rules, Reject, "alice", "chargeback risk")
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.Equal(t, Rejected, rejected.State())
+
+	// without a limit every approval decides
+	unlimited := New("unlimited")
+	unlimited.SetDetails("c1", "5000")
+	changed, err = unlimited.Review(Rules{}, Approve, "", "")
+	require.NoError(t, err)
+	require.True(t, changed)
+}
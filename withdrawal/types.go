@@ -1,11 +1,40 @@
 package withdrawal
 
-import "strings"
+import (
+	"strings"
+	"time"
+)
 
 type withdrawal struct {
 	id          string
+	customer    string
+	amount      string
 	domainState map[domain]State
 	state       State
+	decisions   []Decision
+	createdAt   time.Time
+	decidedAt   time.Time
+	paidAt      time.Time
+	payoutRef   string
+}
+
+// PayoutReferenceHeader carries the payout reference in the response to a payout action.
+const PayoutReferenceHeader = "X-Payout-Reference"
+
+// PayoutRecord is what the payout provider knows about a payment.
+type PayoutRecord struct {
+	Reference    string    `json:"reference"`
+	WithdrawalID string    `json:"withdrawal_id"`
+	PaidAt       time.Time `json:"paid_at"`
+}
+
+// Decision is an approve or reject recorded against one domain.
+type Decision struct {
+	Domain   domain
+	Action   action
+	Reviewer string
+	Reason   string
+	At       time.Time
 }
 
 type domain string
@@ -1,12 +1,15 @@
 package withdrawal
 
 import (
-	"log"
+	"time"
 )
 
 // use in-memory db for this example
 var DB = make(map[string]*withdrawal)
 
+// Ledger stands in for the records of the payout provider.
+var Ledger = []PayoutRecord{}
+
 func New(id string) *withdrawal {
 	return &withdrawal{
 		id: id,
@@ -15,39 +18,88 @@ func New(id string) *withdrawal {
 			Casino: Pending,
 			Manual: Pending,
 		},
-		state: Pending,
+		state:     Pending,
+		createdAt: time.Now(),
 	}
 }
 
+// Approve records the approval of key. Paid out withdrawals take no more decisions.
 func (w *withdrawal) Approve(key domain) {
-	if w.domainState[key] != Pending {
+	if w.domainState[key] != Pending || w.state == Completed {
 		return
 	}
 	w.domainState[key] = Approved
-	// some trigger
-	if w.domainState[Sports] == Approved && w.domainState[Casino] == Approved && w.domainState[Manual] != Rejected || w.domainState[Manual] == Approved {
+	if w.evaluate() == Approved {
 		w.state = Approved
 	}
 }
 
+// Reject records the rejection of key. The manual review can reject after it approved, until the payout.
 func (w *withdrawal) Reject(key domain) {
-	if w.domainState[key] != Pending && key != Manual {
+	if (w.domainState[key] != Pending && key != Manual) || w.state == Completed {
 		return
 	}
 	w.domainState[key] = Rejected
-	if key == Manual {
+	if w.evaluate() == Rejected {
 		w.state = Rejected
 	}
 }
 
+// Evaluate is the approval policy. The manual review decides on its own, without it both automated approvers
+// have to approve. A rejection by an automated approver leaves the withdrawal to the manual review.
+func Evaluate(sports, casino, manual State) State {
+	switch {
+	case manual == Approved || manual == Rejected:
+		return manual
+	case sports == Approved && casino == Approved:
+		return Approved
+	}
+	return Pending
+}
+
+func (w *withdrawal) evaluate() State {
+	return Evaluate(w.domainState[Sports], w.domainState[Casino], w.domainState[Manual])
+}
+
+// Decide applies an approve or reject for key and records who made the
+// decision and why. It reports whether the domain's state changed.
+func (w *withdrawal) Decide(key domain, a action, reviewer, reason string) bool {
+	before := w.domainState[key]
+	wasPending := w.state == Pending
+	switch a {
+	case Approve:
+		w.Approve(key)
+	case Reject:
+		w.Reject(key)
+	default:
+		return false
+	}
+	if w.domainState[key] == before {
+		return false
+	}
+	now := time.Now()
+	w.decisions = append(w.decisions, Decision{
+		Domain:   key,
+		Action:   a,
+		Reviewer: reviewer,
+		Reason:   reason,
+		At:       now,
+	})
+	if wasPending && w.state != Pending {
+		w.decidedAt = now
+	}
+	return true
+}
+
 func (w *withdrawal) Payout() {
 	if w.state != Approved {
-		log.Println("payment blocked for withdrawal", w.id)
 		return
 	}
-	log.Println("payment triggered for withdrawal", w.id)
 	// Some logic
 	w.state = Completed
+	w.paidAt = time.Now()
+	w.payoutRef = "PO-" + w.id
+	Ledger = append(Ledger, PayoutRecord{Reference: w.payoutRef, WithdrawalID: w.id, PaidAt: w.paidAt})
 }
 
 func (w *withdrawal) DomainState(key domain) State {
@@ -57,3 +109,33 @@ func (w *withdrawal) DomainState(key domain) State {
 func (w *withdrawal) State() State {
 	return w.state
 }
+
+func (w *withdrawal) Decisions() []Decision {
+	return w.decisions
+}
+
+func (w *withdrawal) ID() string {
+	return w.id
+}
+
+// SetDetails records who requested the withdrawal and the amount, a decimal in the currency of the account.
+func (w *withdrawal) SetDetails(customer, amount string) {
+	w.customer = customer
+	w.amount = amount
+}
+
+func (w *withdrawal) Customer() string {
+	return w.customer
+}
+
+func (w *withdrawal) Amount() string {
+	return w.amount
+}
+
+func (w *withdrawal) CreatedAt() time.Time {
+	return w.createdAt
+}
+
+func (w *withdrawal) PayoutRef() string {
+	return w.payoutRef
+}
@@ -0,0 +1,40 @@
+package withdrawal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvaluate(t *testing.T) {
+	cases := []struct {
+		sports, casino, manual, want State
+	}{
+		{Pending, Pending, Pending, Pending},
+		{Approved, Pending, Pending, Pending},
+		{Approved, Approved, Pending, Approved},
+		{Approved, Rejected, Pending, Pending},
+		{Rejected, Rejected, Pending, Pending},
+		{Rejected, Rejected, Approved, Approved},
+		{Approved, Approved, Rejected, Rejected},
+		{Pending, Pending, Rejected, Rejected},
+	}
+	for _, c := range cases {
+		require.Equal(t, c.want, Evaluate(c.sports, c.casino, c.manual), "%s/%s/%s", c.sports, c.casino, c.manual)
+	}
+}
+
+func TestDecideFollowsPolicy(t *testing.T) {
+	w := New("a")
+	require.True(t, w.Decide(Sports, Approve, "", ""))
+	require.Equal(t, Pending, w.State())
+	require.True(t, w.Decide(Casino, Approve, "", ""))
+	require.Equal(t, Approved, w.State())
+
+	w = New("b")
+	w.Decide(Sports, Reject, "", "")
+	w.Decide(Casino, Reject, "", "")
+	require.Equal(t, Pending, w.State())
+	w.Decide(Manual, Reject, "alice", "chargeback risk")
+	require.Equal(t, Rejected, w.State())
+}
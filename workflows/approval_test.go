@@ -0,0 +1,139 @@
+package workflows
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/bartke/cadence-withdrawal-approval/activities"
+	"github.com/bartke/cadence-withdrawal-approval/approval"
+	"github.com/bartke/cadence-withdrawal-approval/common"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/cadence"
+	"go.uber.org/cadence/activity"
+	"go.uber.org/cadence/encoded"
+	"go.uber.org/cadence/testsuite"
+)
+
+// mockReview mocks the withdrawal creation and an open manual review.
+func (s *UnitTestSuite) mockReview(env *testsuite.TestWorkflowEnvironment) {
+	env.OnActivity(activities.Default.CreateWithdrawal, mock.Anything, mock.Anything).Return(nil).Once()
+	env.OnActivity(activities.Default.WaitForManual, mock.Anything, mock.Anything).Return("", activity.ErrResultPending).Once()
+}
+
+func (s *UnitTestSuite) Test_RejectionReturnsRejected() {
+	env := s.NewTestWorkflowEnvironment()
+	env.OnActivity(activities.Default.CreateWithdrawal, mock.Anything, mock.Anything).Return(nil).Once()
+	env.OnActivity(activities.Default.WaitForAutomated, mock.Anything, mock.Anything, "sports").Return("REJECT", nil).Once()
+	env.OnActivity(activities.Default.WaitForAutomated, mock.Anything, mock.Anything, "casino").
+		Return("", cadence.NewCustomError("MAINTENANCE"))
+	env.OnActivity(activities.Default.AutoAction, mock.Anything, mock.Anything, "sports", "REJECT").Return(nil).Once()
+	// an automated rejection leaves the withdrawal to the manual review
+	env.OnActivity(activities.Default.WaitForManual, mock.Anything, mock.Anything).Return("REJECTED", nil).After(time.Hour).Once()
+	env.OnActivity(activities.Default.NotifyCustomer, mock.Anything, mock.Anything, "rejected").Return(nil).Once()
+
+	env.ExecuteWorkflow(SampleWithdrawalWorkflow, "test-withdrawal-id")
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	var workflowResult WithdrawalResult
+	s.NoError(env.GetWorkflowResult(&workflowResult))
+	s.Equal("REJECTED", workflowResult.State)
+	s.Equal(DecidedByManual, workflowResult.DecidedBy)
+	s.Equal([]approval.Result{
+		{Source: "sports", Outcome: approval.OutcomeRejected},
+		{Source: "casino", Outcome: approval.OutcomeErrored, Error: "MAINTENANCE"},
+		{Source: "manual", Outcome: approval.OutcomeRejected},
+	}, workflowResult.Approvers)
+	s.Equal(time.Hour, workflowResult.DecidedAt.Sub(workflowResult.StartedAt))
+	env.AssertExpectations(s.T())
+}
+
+func (s *UnitTestSuite) Test_UnreachableApproversLeaveWithdrawalUndecided() {
+	env := s.NewTestWorkflowEnvironment()
+	env.OnActivity(activities.Default.CreateWithdrawal, mock.Anything, mock.Anything).Return(nil).Once()
+	env.OnActivity(activities.Default.WaitForAutomated, mock.Anything, mock.Anything, mock.Anything).
+		Return("", errors.New("connection refused"))
+	env.OnActivity(activities.Default.WaitForManual, mock.Anything, mock.Anything).
+		Return("", cadence.NewCustomError("INVALID_STATE"))
+
+	env.ExecuteWorkflow(SampleWithdrawalWorkflow, "test-withdrawal-id")
+
+	s.True(env.IsWorkflowCompleted())
+	err := env.GetWorkflowError()
+	s.Error(err)
+	customErr, ok := err.(*cadence.CustomError)
+	s.Require().True(ok)
+	s.Equal(approval.Undecided, customErr.Reason())
+	var outcomes []approval.Result
+	s.NoError(customErr.Details(&outcomes))
+	s.Len(outcomes, 3)
+	env.AssertNotCalled(s.T(), "main.autoAction", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (s *UnitTestSuite) Test_AggregationErrorFailsWorkflow() {
+	env := s.NewTestWorkflowEnvironment()
+	s.mockReview(env)
+	env.OnActivity(activities.Default.WaitForAutomated, mock.Anything, mock.Anything, mock.Anything).Return("APPROVE", nil)
+	env.OnActivity(activities.Default.AutoAction, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(cadence.NewCustomError("ERROR:INVALID_ID"))
+
+	env.ExecuteWorkflow(SampleWithdrawalWorkflow, "test-withdrawal-id")
+
+	s.True(env.IsWorkflowCompleted())
+	s.Error(env.GetWorkflowError())
+	env.AssertNotCalled(s.T(), "main.getStatus", mock.Anything, mock.Anything)
+}
+
+func (s *UnitTestSuite) Test_AutomatedApproverResumesPollFromHeartbeat() {
+	// sports takes three polls and fails once in between, casino decides right away
+	var sportsReplies = []int{http.StatusAccepted, http.StatusServiceUnavailable, http.StatusAccepted, http.StatusOK}
+	sports := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.Equal("10ms", r.URL.Query().Get("wait"))
+		status := sportsReplies[0]
+		sportsReplies = sportsReplies[1:]
+		w.WriteHeader(status)
+		if status == http.StatusAccepted {
+			io.WriteString(w, activities.ApproverPending)
+		} else {
+			io.WriteString(w, "APPROVE")
+		}
+	}))
+	defer sports.Close()
+	casino := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "APPROVE")
+	}))
+	defer casino.Close()
+
+	env := s.NewTestWorkflowEnvironment()
+	setActivities(env, func(c *common.Configuration) {
+		c.Approvers.Sports, c.Approvers.Casino, c.Approvers.Poll = sports.URL, casino.URL, 10*time.Millisecond
+	})
+	s.mockReview(env)
+	env.OnActivity(activities.Default.AutoAction, mock.Anything, mock.Anything, mock.Anything, "APPROVE").Return(nil).Twice()
+	env.OnActivity(activities.Default.NotifyCustomer, mock.Anything, mock.Anything, mock.Anything).Return(nil).Twice()
+	env.OnActivity(activities.Default.Payment, mock.Anything, mock.Anything).Return("PO-test-withdrawal-id", nil).Once()
+	var heartbeats []activities.ApproverProgress
+	env.SetOnActivityHeartbeatListener(func(info *activity.Info, details encoded.Values) {
+		var progress activities.ApproverProgress
+		s.NoError(details.Get(&progress))
+		heartbeats = append(heartbeats, progress)
+	})
+
+	env.ExecuteWorkflow(SampleWithdrawalWorkflow, "test-withdrawal-id")
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	s.Empty(sportsReplies)
+	s.Require().Len(heartbeats, 2)
+	s.Equal(int32(0), heartbeats[0].Attempt)
+	s.Equal(1, heartbeats[0].Polls)
+	// the retry continues the count and the clock of the first attempt
+	s.Equal(int32(1), heartbeats[1].Attempt)
+	s.Equal(2, heartbeats[1].Polls)
+	s.True(heartbeats[0].StartedAt.Equal(heartbeats[1].StartedAt))
+	s.Equal(activities.ApproverPending, heartbeats[1].LastStatus)
+	env.AssertExpectations(s.T())
+}
@@ -0,0 +1,56 @@
+package workflows
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/bartke/cadence-withdrawal-approval/activities"
+	"github.com/bartke/cadence-withdrawal-approval/common"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/cadence/activity"
+	"go.uber.org/cadence/encoded"
+	"go.uber.org/cadence/workflow"
+)
+
+func (s *UnitTestSuite) Test_ConfiguredApproverTimeout() {
+	env := s.NewTestWorkflowEnvironment()
+	config := common.DefaultWorkflowConfig()
+	config.Approvers = map[string]common.ActivityConfig{
+		"sports": {ScheduleToStart: time.Second, StartToClose: time.Second},
+	}
+	env.OnActivity(activities.WorkflowConfig, mock.Anything).Return(config, nil).Once()
+	s.mockApproval(env)
+
+	// the remaining time of each automated approver when it starts
+	remaining := map[string]time.Duration{}
+	env.SetOnActivityStartedListener(func(info *activity.Info, ctx context.Context, args encoded.Values) {
+		var withdrawalID, source string
+		if !strings.HasSuffix(info.ActivityType.Name, ".waitForAutomatedActivity") || args.Get(&withdrawalID, &source) != nil {
+			return
+		}
+		remaining[source] = time.Until(activity.GetInfo(ctx).Deadline)
+	})
+
+	env.ExecuteWorkflow(SampleWithdrawalWorkflow, "test-withdrawal-id")
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	s.True(remaining["sports"] > 0 && remaining["sports"] <= time.Second, "sports %v", remaining["sports"])
+	s.True(remaining["casino"] > time.Minute, "casino %v", remaining["casino"])
+	env.AssertExpectations(s.T())
+}
+
+func (s *UnitTestSuite) Test_DefaultConfigVersionKeepsDefaultOptions() {
+	env := s.NewTestWorkflowEnvironment()
+	s.mockApproval(env)
+	env.OnGetVersion(configChangeID, workflow.DefaultVersion, workflowVersions[configChangeID]).
+		Return(workflow.DefaultVersion)
+
+	env.ExecuteWorkflow(SampleWithdrawalWorkflow, "test-withdrawal-id")
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	env.AssertNotCalled(s.T(), "github.com/bartke/cadence-withdrawal-approval/activities.WorkflowConfig", mock.Anything)
+	env.AssertExpectations(s.T())
+}
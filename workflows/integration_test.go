@@ -0,0 +1,322 @@
+package workflows
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	apiclient "github.com/bartke/cadence-withdrawal-approval/api/client"
+	"github.com/bartke/cadence-withdrawal-approval/approval"
+	"github.com/bartke/cadence-withdrawal-approval/approver"
+	"github.com/bartke/cadence-withdrawal-approval/common"
+	"github.com/bartke/cadence-withdrawal-approval/httpclient"
+	"github.com/bartke/cadence-withdrawal-approval/notify"
+	"github.com/bartke/cadence-withdrawal-approval/server"
+	"github.com/bartke/cadence-withdrawal-approval/withdrawal"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/suite"
+	"github.com/uber-go/tally"
+	"go.uber.org/cadence/activity"
+	"go.uber.org/cadence/client"
+	"go.uber.org/cadence/encoded"
+	"go.uber.org/cadence/testsuite"
+)
+
+// IntegrationTestSuite runs SampleWithdrawalWorkflow against the withdrawal server and two automated approvers,
+// served on httptest servers with no activity mocked. Only cadence is simulated, by the test environment.
+type IntegrationTestSuite struct {
+	suite.Suite
+	testsuite.WorkflowTestSuite
+
+	env            *testsuite.TestWorkflowEnvironment
+	server         *httptest.Server
+	client         *apiclient.Client
+	sports, casino *approver.Approver
+	approvers      *httptest.Server
+	// callbacks gets the withdrawal of every manual review that registered its callback with the server.
+	callbacks chan string
+	// automated gets the result of every automated approver activity that finished.
+	automated chan error
+	// reviewed is closed when the reviewer of the test is done.
+	reviewed chan struct{}
+}
+
+func TestIntegrationTestSuite(t *testing.T) {
+	suite.Run(t, new(IntegrationTestSuite))
+}
+
+// testCadence completes the manual reviews in the test environment, the server needs nothing else of cadence
+// without webhook subscriptions.
+type testCadence struct {
+	client.Client
+	env *testsuite.TestWorkflowEnvironment
+}
+
+func (c testCadence) CompleteActivity(ctx context.Context, taskToken []byte, result interface{}, err error) error {
+	return c.env.CompleteActivity(taskToken, result, err)
+}
+
+func (s *IntegrationTestSuite) SetupTest() {
+	s.env = s.NewTestWorkflowEnvironment()
+	s.reviewed = nil
+	s.callbacks = make(chan string, 10)
+	s.automated = make(chan error, 10)
+
+	srv := server.New(testCadence{env: s.env}, "withdrawalGroup", tally.NoopScope)
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		srv.ServeHTTP(w, r)
+		if r.URL.Path == "/registerCallback" {
+			s.callbacks <- r.URL.Query().Get("id")
+		}
+	}))
+	s.client = apiclient.New(s.server.URL, httpclient.New(httpclient.DefaultConfig(), nil))
+
+	// both approvers are served by one server, under the paths of their domains
+	s.sports, s.casino = approver.New("8091", 0), approver.New("8092", 0)
+	mux := http.NewServeMux()
+	mux.Handle("/sports/", http.StripPrefix("/sports", s.sports))
+	mux.Handle("/casino/", http.StripPrefix("/casino", s.casino))
+	s.approvers = httptest.NewServer(mux)
+
+	s.env.SetOnActivityCompletedListener(func(info *activity.Info, result encoded.Value, err error) {
+		if strings.HasSuffix(info.ActivityType.Name, ".waitForAutomatedActivity") {
+			s.automated <- err
+		}
+	})
+	s.configure(nil)
+}
+
+func (s *IntegrationTestSuite) TearDownTest() {
+	if s.reviewed != nil {
+		<-s.reviewed
+	}
+	s.server.Close()
+	s.approvers.Close()
+}
+
+// configure points the activities to the servers, with the defaults changed by configure. The automated approvers
+// are not retried, so that every approver activity finishes once.
+func (s *IntegrationTestSuite) configure(configure func(c *common.Configuration)) {
+	setActivities(s.env, func(c *common.Configuration) {
+		c.Server.URL = s.server.URL
+		c.Approvers.Sports, c.Approvers.Casino = s.approvers.URL+"/sports", s.approvers.URL+"/casino"
+		c.Approvers.Poll = 10 * time.Millisecond
+		c.Notifications = notify.Config{Channels: []string{notify.InApp}}
+		automated := common.ActivityConfig{ScheduleToStart: time.Minute, StartToClose: time.Minute}
+		c.Workflow.Approvers = map[string]common.ActivityConfig{"sports": automated, "casino": automated}
+		if configure != nil {
+			configure(c)
+		}
+	})
+}
+
+// withdrawalID returns a new withdrawal id the approvers decide as given.
+func (s *IntegrationTestSuite) withdrawalID(sports, casino string) string {
+	prefix := uuid.New()[:8]
+	for _, c := range "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ" {
+		id := fmt.Sprintf("%s-%c", prefix, c)
+		if s.sports.Decision(id) == sports && s.casino.Decision(id) == casino {
+			return id
+		}
+	}
+	s.FailNow("no withdrawal id the approvers decide as " + sports + " and " + casino)
+	return ""
+}
+
+// review decides the withdrawal as manual reviewer once its callback is registered and the given number of
+// automated approvers finished. The test waits for the reviewer on teardown.
+func (s *IntegrationTestSuite) review(id, action string, automated int) {
+	callbacks, finished, reviewed := s.callbacks, s.automated, make(chan struct{})
+	s.reviewed = reviewed
+	go func() {
+		defer close(reviewed)
+		timeout := time.After(5 * time.Second)
+		select {
+		case <-callbacks:
+		case <-timeout:
+			s.Fail("manual review did not register its callback")
+			return
+		}
+		for i := 0; i < automated; i++ {
+			select {
+			case <-finished:
+			case <-timeout:
+				s.Fail("automated approvers did not finish")
+				return
+			}
+		}
+		decision := apiclient.Decision{Action: action, Domain: "manual", Reviewer: "alice", Reason: "checked"}
+		s.NoError(s.client.Decide(context.Background(), id, decision))
+	}()
+}
+
+// run executes the workflow of id and returns its result.
+func (s *IntegrationTestSuite) run(id string) WithdrawalResult {
+	s.env.ExecuteWorkflow(SampleWithdrawalWorkflow, id)
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.Require().NoError(s.env.GetWorkflowError())
+	var result WithdrawalResult
+	s.Require().NoError(s.env.GetWorkflowResult(&result))
+	return result
+}
+
+// outcomes returns the approver outcomes by source.
+func outcomes(results []approval.Result) map[string]approval.Outcome {
+	bySource := map[string]approval.Outcome{}
+	for _, r := range results {
+		bySource[r.Source] = r.Outcome
+	}
+	return bySource
+}
+
+// messages returns the subjects of the in-app messages of a withdrawal, which stands in for the customer.
+func (s *IntegrationTestSuite) messages(id string) []string {
+	resp, err := http.Get(s.server.URL + "/messages?customer=" + id)
+	s.Require().NoError(err)
+	defer resp.Body.Close()
+	var messages []struct {
+		Subject string `json:"subject"`
+	}
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&messages))
+	subjects := make([]string, 0, len(messages))
+	for _, m := range messages {
+		subjects = append(subjects, m.Subject)
+	}
+	return subjects
+}
+
+func (s *IntegrationTestSuite) record(id string) withdrawal.Record {
+	record, err := s.client.Withdrawal(context.Background(), id)
+	s.Require().NoError(err)
+	return record
+}
+
+func (s *IntegrationTestSuite) Test_ApprovedByAutomatedApprovers() {
+	id := s.withdrawalID("APPROVE", "APPROVE")
+
+	result := s.run(id)
+
+	s.Equal("COMPLETED", result.State)
+	s.Equal(DecidedByAutomated, result.DecidedBy)
+	s.Equal("PO-"+id, result.PayoutRef)
+	s.Equal(approval.OutcomeApproved, outcomes(result.Approvers)["sports"])
+	s.Equal(approval.OutcomeApproved, outcomes(result.Approvers)["casino"])
+
+	record := s.record(id)
+	s.Equal("COMPLETED", record.State)
+	s.Equal("APPROVED", record.Sports)
+	s.Equal("APPROVED", record.Casino)
+	s.Equal("PENDING", record.Manual)
+	s.Equal("PO-"+id, record.PayoutRef)
+	s.Len(s.messages(id), 2)
+}
+
+func (s *IntegrationTestSuite) Test_RejectedByReviewer() {
+	// an automated rejection leaves the withdrawal to the manual review
+	id := s.withdrawalID("REJECT", "APPROVE")
+	s.review(id, "REJECT", 2)
+
+	result := s.run(id)
+
+	s.Equal("REJECTED", result.State)
+	s.Equal(DecidedByManual, result.DecidedBy)
+	s.Empty(result.PayoutRef)
+	s.Equal(map[string]approval.Outcome{
+		"sports": approval.OutcomeRejected,
+		"casino": approval.OutcomeApproved,
+		"manual": approval.OutcomeRejected,
+	}, outcomes(result.Approvers))
+
+	record := s.record(id)
+	s.Equal("REJECTED", record.State)
+	s.Equal("REJECTED", record.Manual)
+	s.Equal("alice", record.ManualReviewer)
+	s.Equal("checked", record.Reason)
+	s.Len(s.messages(id), 1)
+}
+
+func (s *IntegrationTestSuite) Test_ReviewerOverridesAutomatedRejection() {
+	id := s.withdrawalID("REJECT", "REJECT")
+	s.review(id, "APPROVE", 2)
+
+	result := s.run(id)
+
+	s.Equal("COMPLETED", result.State)
+	s.Equal(DecidedByManual, result.DecidedBy)
+	s.Equal("PO-"+id, result.PayoutRef)
+	s.Equal(approval.OutcomeApproved, outcomes(result.Approvers)["manual"])
+
+	record := s.record(id)
+	s.Equal("COMPLETED", record.State)
+	s.Equal("REJECTED", record.Sports)
+	s.Equal("REJECTED", record.Casino)
+	s.Equal("APPROVED", record.Manual)
+}
+
+func (s *IntegrationTestSuite) Test_UnreachableApproversLeaveDecisionToReviewer() {
+	s.approvers.Close()
+	id := s.withdrawalID("APPROVE", "APPROVE")
+	s.review(id, "APPROVE", 2)
+
+	result := s.run(id)
+
+	s.Equal("COMPLETED", result.State)
+	s.Equal(DecidedByManual, result.DecidedBy)
+	s.Equal(map[string]approval.Outcome{
+		"sports": approval.OutcomeUnreachable,
+		"casino": approval.OutcomeUnreachable,
+		"manual": approval.OutcomeApproved,
+	}, outcomes(result.Approvers))
+	for _, r := range result.Approvers {
+		if r.Source != "manual" {
+			s.Contains(r.Error, r.Source+": ")
+		}
+	}
+	s.Equal("PENDING", s.record(id).Sports)
+}
+
+func (s *IntegrationTestSuite) Test_HungApproversTimeOut() {
+	// the approvers accept the requests but never answer, not even PENDING
+	s.approvers.Close()
+	s.approvers = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	s.configure(func(c *common.Configuration) {
+		c.HTTP.Endpoints = map[string]httpclient.EndpointConfig{
+			"sports": {Timeout: 100 * time.Millisecond},
+			"casino": {Timeout: 100 * time.Millisecond},
+		}
+	})
+	id := s.withdrawalID("APPROVE", "APPROVE")
+	s.review(id, "REJECT", 2)
+
+	result := s.run(id)
+
+	s.Equal("REJECTED", result.State)
+	s.Equal(DecidedByManual, result.DecidedBy)
+	s.Equal(map[string]approval.Outcome{
+		"sports": approval.OutcomeUnreachable,
+		"casino": approval.OutcomeUnreachable,
+		"manual": approval.OutcomeRejected,
+	}, outcomes(result.Approvers))
+	s.Equal("PENDING", s.record(id).Sports)
+}
+
+func (s *IntegrationTestSuite) Test_ReviewerDecidesBeforeSlowApprovers() {
+	s.sports.Delay, s.casino.Delay = time.Hour, time.Hour
+	id := s.withdrawalID("APPROVE", "APPROVE")
+	s.review(id, "REJECT", 0)
+
+	result := s.run(id)
+
+	// the outstanding approvers are cancelled
+	s.Equal("REJECTED", result.State)
+	s.Equal(map[string]approval.Outcome{"manual": approval.OutcomeRejected}, outcomes(result.Approvers))
+	s.Equal("PENDING", s.record(id).Sports)
+}
@@ -0,0 +1,40 @@
+package workflows
+
+import (
+	"time"
+
+	"github.com/uber-go/tally"
+	"go.uber.org/cadence/workflow"
+)
+
+// Business metrics of the withdrawal workflow. They are emitted through workflow.GetMetricsScope, which drops them
+// while a workflow replays, so every execution counts once.
+const (
+	metricWithdrawalsCreated   = "withdrawals_created"
+	metricWithdrawalsUndecided = "withdrawals_undecided"
+	metricApproverDecisions    = "approver_decisions"
+	metricTimeToDecision       = "time_to_decision"
+	metricPayouts              = "payouts"
+	metricPayoutFailures       = "payout_failures"
+)
+
+// decisionBuckets range from automated decisions within seconds to manual reviews taking days.
+var decisionBuckets = tally.DurationBuckets{
+	time.Second, 10 * time.Second, time.Minute, 5 * time.Minute, 15 * time.Minute,
+	time.Hour, 4 * time.Hour, 24 * time.Hour, 7 * 24 * time.Hour,
+}
+
+func countWithdrawals(ctx workflow.Context, name string) {
+	workflow.GetMetricsScope(ctx).Counter(name).Inc(1)
+}
+
+// recordDecision counts the outcome of every approver and how long the withdrawal waited for its decision.
+func recordDecision(ctx workflow.Context, result WithdrawalResult) {
+	scope := workflow.GetMetricsScope(ctx)
+	for _, r := range result.Approvers {
+		scope.Tagged(map[string]string{"approver": r.Source, "outcome": string(r.Outcome)}).
+			Counter(metricApproverDecisions).Inc(1)
+	}
+	scope.Tagged(map[string]string{"state": result.State}).
+		Histogram(metricTimeToDecision, decisionBuckets).RecordDuration(result.DecidedAt.Sub(result.StartedAt))
+}
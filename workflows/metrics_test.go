@@ -0,0 +1,57 @@
+package workflows
+
+import (
+	"github.com/bartke/cadence-withdrawal-approval/approval"
+	"github.com/uber-go/tally"
+	"go.uber.org/cadence/worker"
+)
+
+// counterValue sums the counter name over the snapshot, restricted to the given tags.
+func counterValue(snapshot tally.Snapshot, name string, tags map[string]string) int64 {
+	var value int64
+	for _, c := range snapshot.Counters() {
+		if c.Name() == name && hasTags(c.Tags(), tags) {
+			value += c.Value()
+		}
+	}
+	return value
+}
+
+func hasTags(all, tags map[string]string) bool {
+	for k, v := range tags {
+		if all[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *UnitTestSuite) Test_WorkflowMetrics() {
+	env := s.NewTestWorkflowEnvironment()
+	scope := tally.NewTestScope("", nil)
+	env.SetWorkerOptions(worker.Options{MetricsScope: scope})
+	s.mockApproval(env)
+
+	env.ExecuteWorkflow(SampleWithdrawalWorkflow, "test-withdrawal-id")
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	snapshot := scope.Snapshot()
+	s.Equal(int64(1), counterValue(snapshot, metricWithdrawalsCreated, nil))
+	s.Equal(int64(1), counterValue(snapshot, metricApproverDecisions,
+		map[string]string{"approver": "sports", "outcome": string(approval.OutcomeApproved)}))
+	s.Equal(int64(1), counterValue(snapshot, metricApproverDecisions,
+		map[string]string{"approver": "casino", "outcome": string(approval.OutcomeApproved)}))
+	s.Equal(int64(1), counterValue(snapshot, metricPayouts, nil))
+	s.Equal(int64(0), counterValue(snapshot, metricPayoutFailures, nil))
+
+	var decisions int64
+	for _, h := range snapshot.Histograms() {
+		if h.Name() == metricTimeToDecision && h.Tags()["state"] == "APPROVED" {
+			for _, n := range h.Durations() {
+				decisions += n
+			}
+		}
+	}
+	s.Equal(int64(1), decisions)
+}
@@ -0,0 +1,182 @@
+package workflows
+
+import (
+	"sort"
+	"time"
+
+	"github.com/bartke/cadence-withdrawal-approval/activities"
+	"github.com/bartke/cadence-withdrawal-approval/withdrawal"
+	"go.uber.org/cadence"
+	"go.uber.org/cadence/workflow"
+	"go.uber.org/zap"
+)
+
+// This is registration process where you register the reconciliation workflow. It keeps the name it had in the main
+// package, which running cron schedules start it with.
+func init() {
+	workflow.RegisterWithOptions(ReconciliationWorkflow, workflow.RegisterOptions{Name: "main.ReconciliationWorkflow"})
+}
+
+// Discrepancy kinds found by reconciliation.
+const (
+	ApprovedWithoutPayout  = "APPROVED_WITHOUT_PAYOUT"
+	CompletedWithoutPayout = "COMPLETED_WITHOUT_PAYOUT"
+	PayoutWithoutApproval  = "PAYOUT_WITHOUT_APPROVAL"
+	PendingWithoutWorkflow = "PENDING_WITHOUT_WORKFLOW"
+	WorkflowWithoutRecord  = "WORKFLOW_WITHOUT_WITHDRAWAL"
+)
+
+type (
+	// ReconcileOptions controls a single reconciliation run.
+	ReconcileOptions struct {
+		// Repair pays out approved withdrawals that no workflow is going to pay anymore.
+		Repair bool
+	}
+
+	// Discrepancy is a single mismatch between withdrawals, workflows and payouts.
+	Discrepancy struct {
+		Kind         string
+		WithdrawalID string
+		Detail       string
+		Repaired     bool
+	}
+
+	// ReconciliationReport is the result of a reconciliation run.
+	ReconciliationReport struct {
+		RunAt         time.Time
+		Withdrawals   int
+		OpenWorkflows int
+		Payouts       int
+		Discrepancies []Discrepancy
+	}
+)
+
+// ReconciliationWorkflow compares the withdrawal store, the open withdrawal workflows and the payout provider's
+// records. It is meant to be started with a CronSchedule, each run produces a new report.
+func ReconciliationWorkflow(ctx workflow.Context, opts ReconcileOptions) (ReconciliationReport, error) {
+	ao := workflow.ActivityOptions{
+		ScheduleToStartTimeout: time.Minute,
+		StartToCloseTimeout:    time.Minute,
+		RetryPolicy: &cadence.RetryPolicy{
+			InitialInterval:    time.Second,
+			BackoffCoefficient: 2.0,
+			MaximumInterval:    time.Minute,
+			ExpirationInterval: time.Minute * 5,
+			MaximumAttempts:    5,
+		},
+	}
+	ctx = workflow.WithActivityOptions(ctx, ao)
+	logger := workflow.GetLogger(ctx)
+
+	// the three sources are independent, fetch them in parallel
+	recordsFuture := workflow.ExecuteActivity(ctx, activities.Default.ListWithdrawals)
+	openFuture := workflow.ExecuteActivity(ctx, activities.Default.ListOpenWithdrawalWorkflows)
+	payoutsFuture := workflow.ExecuteActivity(ctx, activities.Default.ListPayouts)
+
+	var records []withdrawal.Record
+	var open []string
+	var payouts []withdrawal.PayoutRecord
+	if err := recordsFuture.Get(ctx, &records); err != nil {
+		return ReconciliationReport{}, err
+	}
+	if err := openFuture.Get(ctx, &open); err != nil {
+		return ReconciliationReport{}, err
+	}
+	if err := payoutsFuture.Get(ctx, &payouts); err != nil {
+		return ReconciliationReport{}, err
+	}
+
+	report := reconcile(records, open, payouts)
+	report.RunAt = workflow.Now(ctx)
+
+	if opts.Repair {
+		for i := range report.Discrepancies {
+			d := &report.Discrepancies[i]
+			if d.Kind != ApprovedWithoutPayout {
+				continue
+			}
+			err := workflow.ExecuteActivity(ctx, activities.Default.Payment, d.WithdrawalID).Get(ctx, nil)
+			if err != nil {
+				d.Detail += ", repair failed: " + err.Error()
+				continue
+			}
+			d.Repaired = true
+		}
+	}
+
+	for _, d := range report.Discrepancies {
+		logger.Warn("Discrepancy found.", zap.String("Kind", d.Kind), zap.String("WithdrawalID", d.WithdrawalID),
+			zap.String("Detail", d.Detail), zap.Bool("Repaired", d.Repaired))
+	}
+	logger.Info("Reconciliation completed.", zap.Int("Withdrawals", report.Withdrawals),
+		zap.Int("OpenWorkflows", report.OpenWorkflows), zap.Int("Payouts", report.Payouts),
+		zap.Int("Discrepancies", len(report.Discrepancies)))
+	return report, nil
+}
+
+// reconcile is deterministic, discrepancies are ordered by withdrawal id and kind.
+func reconcile(records []withdrawal.Record, open []string, payouts []withdrawal.PayoutRecord) ReconciliationReport {
+	report := ReconciliationReport{
+		Withdrawals:   len(records),
+		OpenWorkflows: len(open),
+		Payouts:       len(payouts),
+		Discrepancies: []Discrepancy{},
+	}
+
+	byID := make(map[string]withdrawal.Record, len(records))
+	for _, r := range records {
+		byID[r.ID] = r
+	}
+	running := make(map[string]bool, len(open))
+	for _, id := range open {
+		running[id] = true
+	}
+	paid := make(map[string]withdrawal.PayoutRecord, len(payouts))
+	for _, p := range payouts {
+		paid[p.WithdrawalID] = p
+	}
+
+	add := func(kind, id, detail string) {
+		report.Discrepancies = append(report.Discrepancies, Discrepancy{Kind: kind, WithdrawalID: id, Detail: detail})
+	}
+	for _, r := range records {
+		_, hasPayout := paid[r.ID]
+		switch r.State {
+		case withdrawal.Approved.String():
+			// an open workflow is still going to pay it out
+			if !hasPayout && !running[r.ID] {
+				add(ApprovedWithoutPayout, r.ID, "approved but no payout and no open workflow")
+			}
+		case withdrawal.Completed.String():
+			if !hasPayout {
+				add(CompletedWithoutPayout, r.ID, "completed without payout record "+r.PayoutRef)
+			}
+		case withdrawal.Pending.String():
+			if !running[r.ID] {
+				add(PendingWithoutWorkflow, r.ID, "pending but no open workflow is waiting for a decision")
+			}
+		}
+	}
+	for _, p := range payouts {
+		r, ok := byID[p.WithdrawalID]
+		if !ok {
+			add(PayoutWithoutApproval, p.WithdrawalID, "payout "+p.Reference+" for unknown withdrawal")
+		} else if r.State != withdrawal.Completed.String() {
+			add(PayoutWithoutApproval, p.WithdrawalID, "payout "+p.Reference+" for withdrawal in state "+r.State)
+		}
+	}
+	for _, id := range open {
+		if _, ok := byID[id]; !ok {
+			add(WorkflowWithoutRecord, id, "open workflow for unknown withdrawal")
+		}
+	}
+
+	sort.SliceStable(report.Discrepancies, func(i, j int) bool {
+		a, b := report.Discrepancies[i], report.Discrepancies[j]
+		if a.WithdrawalID != b.WithdrawalID {
+			return a.WithdrawalID < b.WithdrawalID
+		}
+		return a.Kind < b.Kind
+	})
+	return report
+}
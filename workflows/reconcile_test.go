@@ -0,0 +1,45 @@
+package workflows
+
+import (
+	"github.com/bartke/cadence-withdrawal-approval/activities"
+	"github.com/bartke/cadence-withdrawal-approval/withdrawal"
+	"github.com/stretchr/testify/mock"
+)
+
+func (s *UnitTestSuite) Test_ReconciliationWorkflow() {
+	env := s.NewTestWorkflowEnvironment()
+	records := []withdrawal.Record{
+		{ID: "approved-in-flight", State: "APPROVED"},
+		{ID: "approved-stuck", State: "APPROVED"},
+		{ID: "completed", State: "COMPLETED", PayoutRef: "PO-completed"},
+		{ID: "completed-unpaid", State: "COMPLETED", PayoutRef: "PO-completed-unpaid"},
+		{ID: "pending", State: "PENDING"},
+		{ID: "pending-orphan", State: "PENDING"},
+		{ID: "rejected-paid", State: "REJECTED"},
+	}
+	open := []string{"approved-in-flight", "pending", "unknown"}
+	payouts := []withdrawal.PayoutRecord{
+		{Reference: "PO-completed", WithdrawalID: "completed"},
+		{Reference: "PO-rejected-paid", WithdrawalID: "rejected-paid"},
+	}
+	env.OnActivity(activities.Default.ListWithdrawals, mock.Anything).Return(records, nil).Once()
+	env.OnActivity(activities.Default.ListOpenWithdrawalWorkflows, mock.Anything).Return(open, nil).Once()
+	env.OnActivity(activities.Default.ListPayouts, mock.Anything).Return(payouts, nil).Once()
+	env.OnActivity(activities.Default.Payment, mock.Anything, "approved-stuck").Return("PO-approved-stuck", nil).Once()
+
+	env.ExecuteWorkflow(ReconciliationWorkflow, ReconcileOptions{Repair: true})
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	var report ReconciliationReport
+	s.NoError(env.GetWorkflowResult(&report))
+	s.Equal(7, report.Withdrawals)
+	s.Equal([]Discrepancy{
+		{Kind: ApprovedWithoutPayout, WithdrawalID: "approved-stuck", Detail: "approved but no payout and no open workflow", Repaired: true},
+		{Kind: CompletedWithoutPayout, WithdrawalID: "completed-unpaid", Detail: "completed without payout record PO-completed-unpaid"},
+		{Kind: PendingWithoutWorkflow, WithdrawalID: "pending-orphan", Detail: "pending but no open workflow is waiting for a decision"},
+		{Kind: PayoutWithoutApproval, WithdrawalID: "rejected-paid", Detail: "payout PO-rejected-paid for withdrawal in state REJECTED"},
+		{Kind: WorkflowWithoutRecord, WithdrawalID: "unknown", Detail: "open workflow for unknown withdrawal"},
+	}, report.Discrepancies)
+	env.AssertExpectations(s.T())
+}
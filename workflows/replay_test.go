@@ -0,0 +1,32 @@
+package workflows
+
+import (
+	"path/filepath"
+
+	"go.uber.org/cadence/worker"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// historiesGlob matches the histories to replay, the hand-written ones and those downloaded with withdrawal history.
+const historiesGlob = "testdata/histories/*.json"
+
+// Test_ReplayHistories replays histories of executions in flight at different points of the workflow against the
+// current workflow code. baseline_payout.json is the original workflow without version markers. A failure means
+// the change would break executions in that state and has to be gated with workflow.GetVersion.
+func (s *UnitTestSuite) Test_ReplayHistories() {
+	files, err := filepath.Glob(historiesGlob)
+	s.NoError(err)
+	s.NotEmpty(files)
+
+	for _, file := range files {
+		// the replayer only logs when the workflow code does not match the history
+		core, logs := observer.New(zapcore.ErrorLevel)
+		err := worker.ReplayWorkflowHistoryFromJSONFile(zap.New(core), file)
+		s.NoError(err, "replay of %s", filepath.Base(file))
+		for _, entry := range logs.All() {
+			s.Fail("replay of "+filepath.Base(file)+" failed", "%s %v", entry.Message, entry.ContextMap()["PanicError"])
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package workflows
+
+import "go.uber.org/cadence/workflow"
+
+// Version registry of SampleWithdrawalWorkflow.
+//
+// Executions replay their history against the current code, so every change that adds, removes or reorders
+// activities, timers, channels or coroutines in a step has to be gated with workflow.GetVersion. Each structural
+// step has a change id below and the list of versions it went through. To change a step, add a version to its
+// list, bump the maximum in workflowVersions and branch on the value returned by getVersion, keeping the old
+// branches. Executions that entered the step before its gate existed replay as workflow.DefaultVersion.
+//
+// A branch can only be deleted once no open execution recorded its version, and the histories in
+// testdata/histories must keep replaying (see replay_test.go).
+const (
+	// configChangeID gates the start of the workflow.
+	//
+	//   DefaultVersion  activity options are common.DefaultWorkflowConfig
+	//   1               activity options are loaded from the worker configuration with activities.WorkflowConfig
+	configChangeID = "config"
+
+	// approvalChangeID gates step 2, the fan-out to the approvers and the wait for the decision.
+	//
+	//   DefaultVersion  approvers and the manual review keep running after the withdrawal is decided
+	//   1               outstanding approver and manual review activities are cancelled once it is decided
+	//   2               approver results carry an Outcome, aggregation errors fail the workflow and a rejection
+	//                   returns "REJECTED" instead of ""
+	//   3               the workflow decides with withdrawal.Evaluate instead of polling GetStatus after every result
+	approvalChangeID = "approval"
+
+	// payoutChangeID gates step 3, the payout.
+	//
+	//   DefaultVersion  the result of the Payment activity is ignored
+	//   1               Payment returns the payout reference for the workflow result
+	payoutChangeID = "payout"
+
+	// notifyChangeID gates the customer notifications after the decision and after the payout.
+	//
+	//   DefaultVersion  customers are not notified
+	//   1               customers are notified when the withdrawal is approved, rejected and paid out
+	notifyChangeID = "notify"
+)
+
+// workflowVersions is the highest version of every change id this code supports.
+var workflowVersions = map[string]workflow.Version{
+	configChangeID:   1,
+	approvalChangeID: 3,
+	payoutChangeID:   1,
+	notifyChangeID:   1,
+}
+
+// getVersion returns the version of a step for the running execution.
+func getVersion(ctx workflow.Context, changeID string) workflow.Version {
+	return workflow.GetVersion(ctx, changeID, workflow.DefaultVersion, workflowVersions[changeID])
+}
@@ -0,0 +1,88 @@
+package workflows
+
+import (
+	"context"
+
+	"github.com/bartke/cadence-withdrawal-approval/activities"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/cadence/activity"
+	"go.uber.org/cadence/encoded"
+	"go.uber.org/cadence/testsuite"
+	"go.uber.org/cadence/workflow"
+)
+
+// mockApproval approves the withdrawal through both automated approvers while the manual review stays open.
+func (s *UnitTestSuite) mockApproval(env *testsuite.TestWorkflowEnvironment) {
+	env.OnActivity(activities.Default.CreateWithdrawal, mock.Anything, mock.Anything).Return(nil).Once()
+	env.OnActivity(activities.Default.WaitForAutomated, mock.Anything, mock.Anything, "sports").Return("APPROVE", nil).Once()
+	env.OnActivity(activities.Default.WaitForAutomated, mock.Anything, mock.Anything, "casino").Return("APPROVE", nil).Once()
+	env.OnActivity(activities.Default.WaitForManual, mock.Anything, mock.Anything).Return("", activity.ErrResultPending).Once()
+	env.OnActivity(activities.Default.AutoAction, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).Twice()
+	env.OnActivity(activities.Default.NotifyCustomer, mock.Anything, mock.Anything, mock.Anything).Return(nil).Twice()
+	env.OnActivity(activities.Default.Payment, mock.Anything, mock.Anything).Return("PO-test-withdrawal-id", nil).Once()
+}
+
+// runApproval runs an approved withdrawal with the approval step pinned to version and returns the activities
+// that were cancelled.
+func (s *UnitTestSuite) runApproval(version workflow.Version) []string {
+	env := s.NewTestWorkflowEnvironment()
+	s.mockApproval(env)
+	if version < 3 {
+		// the withdrawal server decides
+		env.OnActivity(activities.Default.GetStatus, mock.Anything, mock.Anything).Return("PENDING", nil).Twice()
+		env.OnActivity(activities.Default.GetStatus, mock.Anything, mock.Anything).Return("APPROVED", nil).Once()
+	}
+	env.OnGetVersion(approvalChangeID, workflow.DefaultVersion, workflowVersions[approvalChangeID]).Return(version)
+	var cancelled []string
+	env.SetOnActivityCanceledListener(func(info *activity.Info) {
+		cancelled = append(cancelled, info.ActivityType.Name)
+	})
+
+	env.ExecuteWorkflow(SampleWithdrawalWorkflow, "test-withdrawal-id")
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	var workflowResult WithdrawalResult
+	s.NoError(env.GetWorkflowResult(&workflowResult))
+	s.Equal("COMPLETED", workflowResult.State)
+	env.AssertExpectations(s.T())
+	return cancelled
+}
+
+func (s *UnitTestSuite) Test_ApprovalDefaultVersionLeavesReviewsOpen() {
+	s.Empty(s.runApproval(workflow.DefaultVersion))
+}
+
+func (s *UnitTestSuite) Test_ApprovalCancelsOutstandingReviews() {
+	for _, version := range []workflow.Version{1, 2, 3} {
+		cancelled := s.runApproval(version)
+		s.Len(cancelled, 1, "version %d", version)
+		s.Contains(cancelled[0], "waitForManualActivity")
+	}
+}
+
+func (s *UnitTestSuite) Test_NotifyDefaultVersionSkipsNotifications() {
+	env := s.NewTestWorkflowEnvironment()
+	env.OnActivity(activities.Default.CreateWithdrawal, mock.Anything, mock.Anything).Return(nil).Once()
+	env.OnActivity(activities.Default.WaitForAutomated, mock.Anything, mock.Anything, mock.Anything).Return("APPROVE", nil).Twice()
+	env.OnActivity(activities.Default.WaitForManual, mock.Anything, mock.Anything).Return("", activity.ErrResultPending).Once()
+	env.OnActivity(activities.Default.AutoAction, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).Twice()
+	env.OnActivity(activities.Default.Payment, mock.Anything, mock.Anything).Return("PO-test-withdrawal-id", nil).Once()
+	env.OnGetVersion(notifyChangeID, workflow.DefaultVersion, workflowVersions[notifyChangeID]).Return(workflow.DefaultVersion)
+	var started []string
+	env.SetOnActivityStartedListener(func(info *activity.Info, ctx context.Context, args encoded.Values) {
+		started = append(started, info.ActivityType.Name)
+	})
+
+	env.ExecuteWorkflow(SampleWithdrawalWorkflow, "test-withdrawal-id")
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	var workflowResult WithdrawalResult
+	s.NoError(env.GetWorkflowResult(&workflowResult))
+	s.Equal("COMPLETED", workflowResult.State)
+	for _, name := range started {
+		s.NotContains(name, "notifyCustomerActivity")
+	}
+	env.AssertExpectations(s.T())
+}
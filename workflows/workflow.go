@@ -0,0 +1,296 @@
+// Package workflows holds the withdrawal workflow and the reconciliation workflow. Workers register them by
+// importing the package.
+package workflows
+
+import (
+	"time"
+
+	"github.com/bartke/cadence-withdrawal-approval/activities"
+	"github.com/bartke/cadence-withdrawal-approval/approval"
+	"github.com/bartke/cadence-withdrawal-approval/common"
+	"github.com/bartke/cadence-withdrawal-approval/notify"
+	"go.uber.org/cadence"
+	"go.uber.org/cadence/workflow"
+	"go.uber.org/zap"
+)
+
+// This is registration process where you register all your workflow handlers.
+// The workflow is registered under a stable name so that recorded histories and running executions keep resolving
+// it when the code moves. It is also registered under the name it had in the main package for executions started
+// with that name. New executions start under the name registered last.
+func init() {
+	workflow.RegisterWithOptions(SampleWithdrawalWorkflow, workflow.RegisterOptions{Name: "main.SampleWithdrawalWorkflow"})
+	workflow.RegisterWithOptions(SampleWithdrawalWorkflow, workflow.RegisterOptions{Name: "SampleWithdrawalWorkflow"})
+}
+
+// StateQuery is the query type of SampleWithdrawalWorkflow that returns its WithdrawalResult so far. State is empty
+// until the withdrawal is decided.
+const StateQuery = "state"
+
+type Result struct {
+	Source string
+	Status string
+}
+
+// StateCancelled is the state of a withdrawal whose workflow was cancelled before it was decided.
+const StateCancelled = "CANCELLED"
+
+// Who decided a withdrawal.
+const (
+	DecidedByManual    = "manual"
+	DecidedByAutomated = "automated"
+)
+
+// WithdrawalResult is the result of SampleWithdrawalWorkflow. Executions that completed before it was introduced
+// returned "COMPLETED" on payout and "" otherwise. A workflow cancelled before the decision closes with a
+// *cadence.CanceledError whose details are the result in StateCancelled, with the approver outcomes received so far.
+type WithdrawalResult struct {
+	WithdrawalID string
+	// State is the final state of the withdrawal, COMPLETED once paid out and StateCancelled when cancelled.
+	State string
+	// DecidedBy is DecidedByManual or DecidedByAutomated, empty when the withdrawal server decided on its own.
+	DecidedBy string
+	// Approvers holds the outcome of every approver that finished before the decision.
+	Approvers []approval.Result
+	PayoutRef string
+	StartedAt time.Time
+	DecidedAt time.Time
+	ClosedAt  time.Time
+}
+
+// SampleWithdrawalWorkflow workflow decider
+func SampleWithdrawalWorkflow(ctx workflow.Context, withdrawalID string) (WithdrawalResult, error) {
+	result := WithdrawalResult{WithdrawalID: withdrawalID, StartedAt: workflow.Now(ctx)}
+	err := workflow.SetQueryHandler(ctx, StateQuery, func() (WithdrawalResult, error) {
+		return result, nil
+	})
+	if err != nil {
+		return result, err
+	}
+
+	logger := workflowLogger(ctx, withdrawalID)
+	config, err := loadWorkflowConfig(ctx)
+	if err != nil {
+		logger.Error("Failed to load workflow configuration", zap.Error(err))
+		return result, err
+	}
+	ctxNotify := workflow.WithActivityOptions(ctx, config.Notify.Options())
+
+	// step 1, create new withdrawal report
+	ctx1 := workflow.WithActivityOptions(ctx, config.Create.Options())
+
+	err = workflow.ExecuteActivity(ctx1, activities.Default.CreateWithdrawal, withdrawalID).Get(ctx1, nil)
+	if err != nil {
+		logger.Error("Failed to create withdrawal report", zap.Error(err))
+		return result, err
+	}
+	countWithdrawals(ctx, metricWithdrawalsCreated)
+
+	// step 2, wait for the withdrawal report to be approved (or rejected)
+	approvalVersion := getVersion(ctx, approvalChangeID)
+	ctx2 := workflow.WithActivityOptions(ctx, config.Payout.Options())
+
+	// step 2.1 the approvers and the aggregation share the approval options, approvers can override them
+	ctx3 := workflow.WithActivityOptions(ctx, config.Approval.Options())
+
+	var status string
+	switch {
+	case approvalVersion < 2:
+		status, err = awaitLegacyDecision(ctx3, withdrawalID, approvalVersion)
+	case approvalVersion == 2:
+		status, result.Approvers, err = approval.AwaitPolled(ctx3, withdrawalID, config)
+	default:
+		status, result.Approvers, err = approval.Await(ctx3, withdrawalID, config)
+	}
+	if cadence.IsCanceledError(err) {
+		logger.Info("Workflow cancelled.", zap.Any("Outcomes", result.Approvers))
+		result.State = StateCancelled
+		result.ClosedAt = workflow.Now(ctx)
+		return result, cadence.NewCanceledError(result)
+	}
+	if err != nil {
+		logger.Error("Withdrawal not decided.", zap.Any("Outcomes", result.Approvers), zap.Error(err))
+		countWithdrawals(ctx, metricWithdrawalsUndecided)
+		result.ClosedAt = workflow.Now(ctx)
+		return result, err
+	}
+	result.State = status
+	result.DecidedBy = decidedBy(result.Approvers)
+	result.DecidedAt = workflow.Now(ctx)
+	recordDecision(ctx, result)
+	notifying := getVersion(ctx, notifyChangeID) >= 1
+
+	if status != "APPROVED" {
+		if notifying && status == "REJECTED" {
+			notifyCustomer(ctxNotify, withdrawalID, notify.Rejected)
+		}
+		logger.Info("Workflow completed.", zap.String("WithdrawalStatus", status))
+		result.ClosedAt = workflow.Now(ctx)
+		return result, nil
+	}
+	if notifying {
+		notifyCustomer(ctxNotify, withdrawalID, notify.Approved)
+	}
+
+	// step 3, trigger payment to the withdrawal
+	payoutVersion := getVersion(ctx, payoutChangeID)
+	payment := workflow.ExecuteActivity(ctx2, activities.Default.Payment, withdrawalID)
+	if payoutVersion < 1 {
+		err = payment.Get(ctx2, nil)
+	} else {
+		err = payment.Get(ctx2, &result.PayoutRef)
+	}
+	if err != nil {
+		logger.Info("Workflow completed with payment failed.", zap.Error(err))
+		countWithdrawals(ctx, metricPayoutFailures)
+		result.ClosedAt = workflow.Now(ctx)
+		return result, err
+	}
+	result.State = "COMPLETED"
+	countWithdrawals(ctx, metricPayouts)
+
+	if notifying {
+		notifyCustomer(ctxNotify, withdrawalID, notify.Completed)
+	}
+	result.ClosedAt = workflow.Now(ctx)
+
+	logger.Info("Workflow completed with withdrawal payment completed.", zap.String("PayoutRef", result.PayoutRef))
+	return result, nil
+}
+
+// decidedBy derives who decided from the approver outcomes, the manual review decides on its own.
+func decidedBy(outcomes []approval.Result) string {
+	if len(outcomes) == 0 {
+		return ""
+	}
+	for _, r := range outcomes {
+		if r.Source == "manual" && r.Decided() {
+			return DecidedByManual
+		}
+	}
+	return DecidedByAutomated
+}
+
+// workflowLogger adds the withdrawal to the workflow logger, which has the workflow and run.
+func workflowLogger(ctx workflow.Context, withdrawalID string) *zap.Logger {
+	return workflow.GetLogger(ctx).With(zap.String("WithdrawalID", withdrawalID))
+}
+
+// notifyCustomer is best effort, a failed notification never fails the withdrawal.
+func notifyCustomer(ctx workflow.Context, withdrawalID, outcome string) {
+	err := workflow.ExecuteActivity(ctx, activities.Default.NotifyCustomer, withdrawalID, outcome).Get(ctx, nil)
+	if err != nil {
+		workflowLogger(ctx, withdrawalID).Warn("Failed to notify customer.", zap.String("Outcome", outcome), zap.Error(err))
+	}
+}
+
+// loadWorkflowConfig captures the activity options of the execution.
+func loadWorkflowConfig(ctx workflow.Context) (common.WorkflowConfig, error) {
+	config := common.DefaultWorkflowConfig()
+	if getVersion(ctx, configChangeID) < 1 {
+		return config, nil
+	}
+	lao := workflow.LocalActivityOptions{ScheduleToCloseTimeout: 10 * time.Second}
+	ctx = workflow.WithLocalActivityOptions(ctx, lao)
+	err := workflow.ExecuteLocalActivity(ctx, activities.WorkflowConfig).Get(ctx, &config)
+	return config, err
+}
+
+// awaitLegacyDecision is step 2 of executions that entered it before approvalChangeID version 2. Approver errors are
+// forwarded as empty statuses and a failing status check leaves the workflow waiting until it is cancelled, which
+// returns the cancellation error.
+func awaitLegacyDecision(ctx workflow.Context, withdrawalID string, version workflow.Version) (string, error) {
+	logger := workflowLogger(ctx, withdrawalID)
+	waitChannel := workflow.NewChannel(ctx)
+	syncChannel := workflow.NewChannel(ctx)
+
+	// the reviews are cancelled once the withdrawal is decided, the status loop is not
+	reviewCtx, cancelReviews := workflow.WithCancel(ctx)
+
+	// we're trying to reach two auto approvals in parallel
+
+	workflow.Go(reviewCtx, func(ctx workflow.Context) {
+		var status string
+		err := workflow.ExecuteActivity(ctx, activities.Default.WaitForAutomated, withdrawalID, "sports").Get(ctx, &status)
+		if cadence.IsCanceledError(err) {
+			return
+		}
+		if err != nil {
+			logger.Error("Activity failed", zap.Error(err))
+		}
+		syncChannel.Send(ctx, Result{"sports", status})
+	})
+
+	workflow.Go(reviewCtx, func(ctx workflow.Context) {
+		var status string
+		err := workflow.ExecuteActivity(ctx, activities.Default.WaitForAutomated, withdrawalID, "casino").Get(ctx, &status)
+		if cadence.IsCanceledError(err) {
+			return
+		}
+		if err != nil {
+			logger.Error("Activity failed", zap.Error(err))
+		}
+		syncChannel.Send(ctx, Result{"casino", status})
+	})
+
+	// add the manual workflow
+
+	workflow.Go(reviewCtx, func(ctx workflow.Context) {
+		var status string
+		err := workflow.ExecuteActivity(ctx, activities.Default.WaitForManual, withdrawalID).Get(ctx, &status)
+		if cadence.IsCanceledError(err) {
+			return
+		}
+		if err != nil {
+			logger.Error("Activity failed", zap.Error(err))
+		}
+		syncChannel.Send(ctx, Result{"manual", status})
+	})
+
+	// wait for the coroutinue to check in.
+
+	workflow.Go(ctx, func(ctx workflow.Context) {
+		var status string
+		for {
+			err := workflow.ExecuteActivity(ctx, activities.Default.GetStatus, withdrawalID).Get(ctx, &status)
+			if err != nil {
+				return
+			}
+
+			if status != "PENDING" {
+				logger.Info("Status changed "+status, zap.String("WithdrawalStatus", status))
+				waitChannel.Send(ctx, status)
+				break
+			}
+
+			var v interface{}
+			syncChannel.Receive(ctx, &v)
+			switch r := v.(type) {
+			case error:
+				// ignore
+			case Result:
+				logger.Info("Result received "+r.Source, zap.String("WithdrawalStatus", status))
+				err := workflow.ExecuteActivity(ctx, activities.Default.AutoAction, withdrawalID, r.Source, r.Status).Get(ctx, nil)
+				if err != nil {
+					return
+				}
+			}
+		}
+	})
+
+	var status string
+	selector := workflow.NewSelector(ctx)
+	selector.AddReceive(waitChannel, func(c workflow.Channel, more bool) {
+		c.Receive(ctx, &status)
+	})
+	// the status loop stops without a status when it is cancelled
+	selector.AddReceive(ctx.Done(), func(c workflow.Channel, more bool) {})
+	selector.Select(ctx)
+	if status == "" {
+		return "", ctx.Err()
+	}
+	if version >= 1 {
+		cancelReviews()
+	}
+	return status, nil
+}
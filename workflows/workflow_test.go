@@ -0,0 +1,167 @@
+package workflows
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bartke/cadence-withdrawal-approval/activities"
+	"github.com/bartke/cadence-withdrawal-approval/approval"
+	"github.com/bartke/cadence-withdrawal-approval/common"
+	"github.com/bartke/cadence-withdrawal-approval/httpclient"
+	"github.com/bartke/cadence-withdrawal-approval/withdrawal"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/cadence"
+	"go.uber.org/cadence/activity"
+	"go.uber.org/cadence/testsuite"
+	"go.uber.org/cadence/worker"
+	"go.uber.org/cadence/workflow"
+)
+
+type UnitTestSuite struct {
+	suite.Suite
+	testsuite.WorkflowTestSuite
+}
+
+func TestUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(UnitTestSuite))
+}
+
+// setActivities runs the activities of env with the defaults changed by configure.
+func setActivities(env *testsuite.TestWorkflowEnvironment, configure func(c *common.Configuration)) {
+	c := common.Configuration{
+		Approvers: common.ApproversConfig{Poll: common.DefaultApproverPoll},
+		Workflow:  common.DefaultWorkflowConfig(),
+		HTTP:      httpclient.DefaultConfig(),
+	}
+	configure(&c)
+	a := activities.New(c, nil, nil)
+	env.SetWorkerOptions(worker.Options{BackgroundActivityContext: activities.WithContext(context.Background(), a)})
+}
+
+func (s *UnitTestSuite) Test_WorkflowWithMockActivities() {
+	env := s.NewTestWorkflowEnvironment()
+	env.OnActivity(activities.Default.CreateWithdrawal, mock.Anything, mock.Anything).Return(nil).Once()
+	env.OnActivity(activities.Default.WaitForManual, mock.Anything, mock.Anything).Return("APPROVED", nil).Once()
+	env.OnActivity(activities.Default.Payment, mock.Anything, mock.Anything).Return("PO-test-withdrawal-id", nil).Once()
+
+	env.ExecuteWorkflow(SampleWithdrawalWorkflow, "test-withdrawal-id")
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	var workflowResult WithdrawalResult
+	err := env.GetWorkflowResult(&workflowResult)
+	s.NoError(err)
+	s.Equal("COMPLETED", workflowResult.State)
+	s.Equal("PO-test-withdrawal-id", workflowResult.PayoutRef)
+	env.AssertExpectations(s.T())
+}
+
+func (s *UnitTestSuite) Test_StateQuery() {
+	env := s.NewTestWorkflowEnvironment()
+	env.OnActivity(activities.Default.CreateWithdrawal, mock.Anything, mock.Anything).Return(nil).Once()
+	env.OnActivity(activities.Default.WaitForManual, mock.Anything, mock.Anything).After(time.Hour).Return("REJECTED", nil).Once()
+
+	var pending WithdrawalResult
+	env.RegisterDelayedCallback(func() {
+		value, err := env.QueryWorkflow(StateQuery)
+		s.NoError(err)
+		s.NoError(value.Get(&pending))
+	}, time.Minute)
+	env.ExecuteWorkflow(SampleWithdrawalWorkflow, "test-withdrawal-id")
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	s.Equal("test-withdrawal-id", pending.WithdrawalID)
+	s.Empty(pending.State)
+
+	value, err := env.QueryWorkflow(StateQuery)
+	s.NoError(err)
+	var closed WithdrawalResult
+	s.NoError(value.Get(&closed))
+	s.Equal("REJECTED", closed.State)
+}
+
+func (s *UnitTestSuite) Test_WorkflowWithMockServer() {
+	env := s.NewTestWorkflowEnvironment()
+
+	// setup mock withdrawal server
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/text")
+		switch r.URL.Path {
+		case "/create":
+		case "/registerCallback":
+			taskToken := []byte(r.PostFormValue("task_token"))
+			// simulate the withdrawal is approved one hour later.
+			env.RegisterDelayedCallback(func() {
+				env.CompleteActivity(taskToken, "APPROVED", nil)
+			}, time.Hour)
+		case "/action":
+			if r.URL.Query().Get("type") == "payout" {
+				w.Header().Set(withdrawal.PayoutReferenceHeader, "PO-"+r.URL.Query().Get("id"))
+			}
+		}
+		io.WriteString(w, "SUCCEED")
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	// pointing server to test mock
+	setActivities(env, func(c *common.Configuration) { c.Server.URL = server.URL })
+
+	env.ExecuteWorkflow(SampleWithdrawalWorkflow, "test-withdrawal-id")
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	var workflowResult WithdrawalResult
+	err := env.GetWorkflowResult(&workflowResult)
+	s.NoError(err)
+	s.Equal("COMPLETED", workflowResult.State)
+	s.Equal("PO-test-withdrawal-id", workflowResult.PayoutRef)
+	env.AssertExpectations(s.T())
+}
+
+// runCancelled cancels a withdrawal while the casino approver and the manual review are pending, with the approval
+// step pinned to version, and returns the workflow error.
+func (s *UnitTestSuite) runCancelled(version workflow.Version) error {
+	env := s.NewTestWorkflowEnvironment()
+	env.OnActivity(activities.Default.CreateWithdrawal, mock.Anything, mock.Anything).Return(nil).Once()
+	env.OnActivity(activities.Default.WaitForAutomated, mock.Anything, mock.Anything, "sports").Return("APPROVE", nil).Once()
+	env.OnActivity(activities.Default.WaitForAutomated, mock.Anything, mock.Anything, "casino").
+		Return("", activity.ErrResultPending).Once()
+	env.OnActivity(activities.Default.WaitForManual, mock.Anything, mock.Anything).Return("", activity.ErrResultPending).Once()
+	env.OnActivity(activities.Default.AutoAction, mock.Anything, mock.Anything, "sports", "APPROVE").Return(nil)
+	env.OnActivity(activities.Default.GetStatus, mock.Anything, mock.Anything).Return("PENDING", nil)
+	env.OnGetVersion(approvalChangeID, workflow.DefaultVersion, workflowVersions[approvalChangeID]).Return(version)
+	env.RegisterDelayedCallback(env.CancelWorkflow, time.Minute)
+
+	env.ExecuteWorkflow(SampleWithdrawalWorkflow, "test-withdrawal-id")
+
+	s.True(env.IsWorkflowCompleted(), "version %d", version)
+	env.AssertNotCalled(s.T(), "main.paymentActivity", mock.Anything, mock.Anything)
+	return env.GetWorkflowError()
+}
+
+func (s *UnitTestSuite) Test_CancelWhileApproversPending() {
+	for _, version := range []workflow.Version{workflow.DefaultVersion, 1, 2, 3} {
+		err := s.runCancelled(version)
+		s.True(cadence.IsCanceledError(err), "version %d: %v", version, err)
+	}
+}
+
+func (s *UnitTestSuite) Test_CancelledResult() {
+	err := s.runCancelled(3)
+	canceledErr, ok := err.(*cadence.CanceledError)
+	s.Require().True(ok, "%v", err)
+	var result WithdrawalResult
+	s.NoError(canceledErr.Details(&result))
+	s.Equal("test-withdrawal-id", result.WithdrawalID)
+	s.Equal(StateCancelled, result.State)
+	s.Empty(result.DecidedBy)
+	s.Equal([]approval.Result{{Source: "sports", Outcome: approval.OutcomeApproved}}, result.Approvers)
+	s.Equal(time.Minute, result.ClosedAt.Sub(result.StartedAt))
+}